@@ -0,0 +1,274 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAccessors(t *testing.T) {
+	// unexported is the error of the unexported fields
+	factories := map[string]struct {
+		factory    AccessorFactory
+		unexported error
+	}{
+		"unsafe":  {factory: NewUnsafeAccessor},
+		"reflect": {factory: NewReflectAccessor, unexported: errs.ErrUnexportedField},
+	}
+	for name, f := range factories {
+		t.Run(name, func(t *testing.T) {
+			testAccessorSet(t, f.factory, f.unexported)
+			testAccessorFetch(t, f.factory, f.unexported)
+		})
+	}
+}
+
+type accessorTestInner struct {
+	Street string
+}
+
+type AccessorTestEmbedded struct {
+	Street string
+}
+
+type accessorTestModel struct {
+	ID      int64 `orm:"column=id_t"`
+	Name    string
+	Address sql.NullString
+	Inner   *accessorTestInner
+	Score   float64
+	*AccessorTestEmbedded
+	level int
+}
+
+// testAccessorSet is the conformance suite every UnsafeAccessor
+// implementation has to pass when scanning rows.
+func testAccessorSet(t *testing.T, factory AccessorFactory, unexported error) {
+	m, err := (&model.Registry{}).Get(&accessorTestModel{})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		cols    []string
+		row     []any
+		entity  any
+		wantRes *accessorTestModel
+		wantErr error
+	}{
+		{
+			name:   "all columns",
+			cols:   []string{"id_t", "name", "address", "score"},
+			row:    []any{1, "wang", "addr", 1.5},
+			entity: &accessorTestModel{},
+			wantRes: &accessorTestModel{
+				ID:      1,
+				Name:    "wang",
+				Address: sql.NullString{String: "addr", Valid: true},
+				Score:   1.5,
+			},
+		},
+		{
+			name:    "column subset out of order",
+			cols:    []string{"name", "id_t"},
+			row:     []any{"li", 2},
+			entity:  &accessorTestModel{Score: 3},
+			wantRes: &accessorTestModel{ID: 2, Name: "li", Score: 3},
+		},
+		{
+			name:   "embedded pointer",
+			cols:   []string{"id_t", "street"},
+			row:    []any{1, "main"},
+			entity: &accessorTestModel{},
+			wantRes: &accessorTestModel{
+				ID:                   1,
+				AccessorTestEmbedded: &AccessorTestEmbedded{Street: "main"},
+			},
+		},
+		{
+			name:    "unexported",
+			cols:    []string{"level"},
+			row:     []any{3},
+			entity:  &accessorTestModel{},
+			wantRes: &accessorTestModel{level: 3},
+			wantErr: unexported,
+		},
+		{
+			name:    "null value",
+			cols:    []string{"address"},
+			row:     []any{nil},
+			entity:  &accessorTestModel{},
+			wantRes: &accessorTestModel{},
+		},
+		{
+			name:    "unknown column",
+			cols:    []string{"id_t", "unknown"},
+			row:     []any{1, "x"},
+			entity:  &accessorTestModel{},
+			wantErr: errs.ErrUnknownColumn,
+		},
+		{
+			name:    "scan failed",
+			cols:    []string{"id_t"},
+			row:     []any{"not_int"},
+			entity:  &accessorTestModel{},
+			wantErr: errs.ErrScanFailed,
+		},
+		{
+			name:    "no entity",
+			cols:    []string{"id_t"},
+			row:     []any{1},
+			wantErr: errs.ErrNoEntity,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run("set "+tc.name, func(t *testing.T) {
+			rows := mockRows(t, tc.cols, tc.row)
+			defer rows.Close()
+			require.True(t, rows.Next())
+
+			uac := factory(m)
+			if tc.entity != nil {
+				uac.Access(tc.entity)
+			}
+			err := uac.Set(rows)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, tc.entity)
+		})
+	}
+}
+
+// testAccessorFetch is the conformance suite every UnsafeAccessor
+// implementation has to pass when reading fields.
+func testAccessorFetch(t *testing.T, factory AccessorFactory, unexported error) {
+	m, err := (&model.Registry{}).Get(&accessorTestModel{})
+	require.NoError(t, err)
+	inner := &accessorTestInner{Street: "main"}
+	entity := &accessorTestModel{
+		ID:                   1,
+		Name:                 "wang",
+		Address:              sql.NullString{String: "addr", Valid: true},
+		Inner:                inner,
+		AccessorTestEmbedded: &AccessorTestEmbedded{Street: "main"},
+		level:                2,
+	}
+
+	testCases := []struct {
+		name    string
+		field   string
+		entity  any
+		wantVal any
+		wantErr error
+	}{
+		{
+			name:    "int",
+			field:   "ID",
+			entity:  entity,
+			wantVal: int64(1),
+		},
+		{
+			name:    "valuer",
+			field:   "Address",
+			entity:  entity,
+			wantVal: sql.NullString{String: "addr", Valid: true},
+		},
+		{
+			name:    "pointer",
+			field:   "Inner",
+			entity:  entity,
+			wantVal: inner,
+		},
+		{
+			name:    "embedded pointer",
+			field:   "Street",
+			entity:  entity,
+			wantVal: "main",
+		},
+		{
+			name:    "nil embedded pointer",
+			field:   "Street",
+			entity:  &accessorTestModel{},
+			wantVal: "",
+		},
+		{
+			name:    "unexported",
+			field:   "level",
+			entity:  entity,
+			wantVal: 2,
+			wantErr: unexported,
+		},
+		{
+			name:    "zero value",
+			field:   "Score",
+			entity:  entity,
+			wantVal: float64(0),
+		},
+		{
+			name:    "unknown field",
+			field:   "Unknown",
+			entity:  entity,
+			wantErr: errs.ErrUnknownField,
+		},
+		{
+			name:    "no entity",
+			field:   "ID",
+			wantErr: errs.ErrNoEntity,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run("fetch "+tc.name, func(t *testing.T) {
+			uac := factory(m)
+			if tc.entity != nil {
+				uac.Access(tc.entity)
+			}
+			val, err := uac.Fetch(tc.field)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func mockRows(t *testing.T, cols []string, row []any) *sql.Rows {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	vals := make([]driver.Value, 0, len(row))
+	for _, v := range row {
+		vals = append(vals, v)
+	}
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(cols).AddRow(vals...))
+	rows, err := mockDB.Query("SELECT")
+	require.NoError(t, err)
+	return rows
+}
+
+func TestWithAccessorFactory(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	calls := 0
+	db := OpenDB(mockDB, WithDialect(MySQLDialect), WithAccessorFactory(func(m *model.Model) UnsafeAccessor {
+		calls++
+		return NewReflectAccessor(m)
+	}))
+	mock.ExpectQuery("SELECT \\* FROM `accessor_test_model`;").
+		WillReturnRows(sqlmock.NewRows([]string{"id_t", "name"}).AddRow(1, "wang"))
+
+	res, err := NewSelector[accessorTestModel](db).Get(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.Equal(t, &accessorTestModel{ID: 1, Name: "wang"}, res)
+	assert.Equal(t, 1, calls)
+}
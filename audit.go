@@ -0,0 +1,264 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/model"
+	"reflect"
+	"time"
+)
+
+// Audited is implemented by the models whose UPDATEs and DELETEs are
+// recorded by the AuditSink set with WithAudit.
+type Audited interface {
+	Audited()
+}
+
+type actorKey struct{}
+
+// WithActor sets who runs the statements issued with ctx, as recorded in
+// the audit trail.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFrom(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// AuditRecord is the change of one row by an UPDATE or a DELETE.
+type AuditRecord struct {
+	Actor string
+	Table string
+	Kind  middleware.OpKind
+	PK    any
+	// Diff holds the changed columns of an update, and every column of a
+	// delete with a nil New.
+	Diff map[string]Change
+	At   time.Time
+}
+
+// Change is the value of a column before and after a statement.
+type Change struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// AuditSink stores the records of a statement. sess is the transaction the
+// statement runs in, so that the records are committed or rolled back along
+// with the change.
+type AuditSink interface {
+	Write(ctx context.Context, sess Session, records []AuditRecord) error
+}
+
+type AuditSinkFunc func(ctx context.Context, sess Session, records []AuditRecord) error
+
+func (f AuditSinkFunc) Write(ctx context.Context, sess Session, records []AuditRecord) error {
+	return f(ctx, sess, records)
+}
+
+// WithAudit records the UPDATEs and DELETEs of the Audited models with sink.
+// Statements run on a DB are wrapped in a transaction, the rows they match
+// are read again before and after them.
+func WithAudit(sink AuditSink) DBOptions {
+	return func(db *DB) {
+		db.audit = sink
+	}
+}
+
+// AuditLog is the row written by TableSink for every AuditRecord, PK and
+// Diff hold JSON.
+type AuditLog struct {
+	Id        int64
+	Actor     string
+	Table     string `orm:"column=table_name"`
+	Op        string
+	Pk        string
+	Diff      string
+	CreatedAt time.Time
+}
+
+// TableSink writes the records to the audit_log table, see AuditLog.
+func TableSink() AuditSink {
+	return AuditSinkFunc(writeAuditLogs)
+}
+
+func writeAuditLogs(ctx context.Context, sess Session, records []AuditRecord) error {
+	logs := make([]*AuditLog, 0, len(records))
+	for _, r := range records {
+		pk, err := json.Marshal(r.PK)
+		if err != nil {
+			return err
+		}
+		diff, err := json.Marshal(r.Diff)
+		if err != nil {
+			return err
+		}
+		logs = append(logs, &AuditLog{
+			Actor:     r.Actor,
+			Table:     r.Table,
+			Op:        r.Kind.String(),
+			Pk:        string(pk),
+			Diff:      string(diff),
+			CreatedAt: r.At,
+		})
+	}
+	return NewInsertor[AuditLog](sess).Columns("Actor", "Table", "Op", "Pk", "Diff", "CreatedAt").
+		Values(logs...).Exec(&middleware.Context{Ctx: ctx}).Err()
+}
+
+func auditedModel[T any]() bool {
+	_, ok := any(new(T)).(Audited)
+	return ok
+}
+
+// auditExec runs exec on sess. When T is audited, it runs in a transaction
+// of sess instead, in which the rows matched by where are read before exec,
+// read again by primary key after an update, and recorded by the sink. sets
+// are the fields assigned by an update, the rows are read again by the
+// primary key assigned when there is one.
+func auditExec[T any](ctx *middleware.Context, c core, sess session, kind middleware.OpKind,
+	where []Predicate, skip scopeSkip, sets []set,
+	exec func(ctx *middleware.Context, sess session) (sql.Result, error)) (sql.Result, error) {
+	if c.audit == nil || !auditedModel[T]() {
+		return exec(ctx, sess)
+	}
+	if _, ok := shardingRuleOf[T](); ok {
+		return nil, errs.ErrShardInTx
+	}
+	m, err := c.registry.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	if m.PK == nil {
+		return nil, errs.ErrNoPrimaryKey
+	}
+	if !m.PK.Type.Comparable() {
+		return nil, errs.ErrUnsupportedType
+	}
+
+	var tx *Transaction
+	switch s := sess.(type) {
+	case *Transaction:
+		tx = s
+	case *DB:
+		tx, err = s.BeginTx(ctx.Ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = tx.RollBackUnlessCommit()
+		}()
+	default:
+		return nil, errs.ErrUnsupported
+	}
+
+	before, err := auditRead[T](ctx.Ctx, tx, where, skip)
+	if err != nil {
+		return nil, err
+	}
+	res, err := exec(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	after := make(map[any]*T, len(before))
+	// moved is the row under the primary key assigned by the update, the
+	// key being unique it is the one of every row matched
+	var moved *T
+	newPK, movesPK := assignedPK(m, sets)
+	if kind == middleware.KindUpdate && len(before) > 0 {
+		p := C(m.PK.GoName).Eq(newPK)
+		if !movesPK {
+			p = C(m.PK.GoName).Eq(fieldOf(before[0], m.PK))
+			for _, t := range before[1:] {
+				p = p.Or(C(m.PK.GoName).Eq(fieldOf(t, m.PK)))
+			}
+		}
+		// the update may take the rows out of the default scopes
+		rows, err := auditRead[T](ctx.Ctx, tx, []Predicate{p}, scopeSkip{all: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range rows {
+			after[fieldOf(t, m.PK)] = t
+			moved = t
+		}
+	}
+
+	actor, _ := ActorFrom(ctx.Ctx)
+	now := time.Now()
+	records := make([]AuditRecord, 0, len(before))
+	for _, t := range before {
+		pk := fieldOf(t, m.PK)
+		cur := after[pk]
+		if movesPK {
+			cur = moved
+		}
+		diff := diffOf(m, t, cur)
+		if len(diff) == 0 {
+			continue
+		}
+		records = append(records, AuditRecord{
+			Actor: actor,
+			Table: m.TableName,
+			Kind:  kind,
+			PK:    pk,
+			Diff:  diff,
+			At:    now,
+		})
+	}
+	if len(records) > 0 {
+		if err = c.audit.Write(ctx.Ctx, tx, records); err != nil {
+			return nil, err
+		}
+	}
+	if tx != sess {
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// assignedPK returns the value sets assign to the primary key of m.
+func assignedPK(m *model.Model, sets []set) (any, bool) {
+	for _, s := range sets {
+		if s.field == m.PK {
+			return s.val, true
+		}
+	}
+	return nil, false
+}
+
+// auditRead reads the rows directly on sess, so that middlewares such as the
+// cache cannot serve them.
+func auditRead[T any](ctx context.Context, sess session, where []Predicate, skip scopeSkip) ([]*T, error) {
+	s := NewSelector[T](sess).Where(where...)
+	s.skip = skip
+	return s.query(&middleware.Context{Ctx: ctx, Type: middleware.OpQuery}, sess)
+}
+
+func fieldOf(entity any, fi *model.FieldInfo) any {
+	return fi.Value(reflect.ValueOf(entity).Elem()).Interface()
+}
+
+// diffOf returns the columns whose value differs between old and cur, cur
+// being nil when the row is gone.
+func diffOf[T any](m *model.Model, old, cur *T) map[string]Change {
+	diff := make(map[string]Change, len(m.Fields))
+	for _, fi := range m.Fields {
+		if cur == nil {
+			diff[fi.ColName] = Change{Old: fieldOf(old, fi)}
+			continue
+		}
+		o, n := fieldOf(old, fi), fieldOf(cur, fi)
+		if !reflect.DeepEqual(o, n) {
+			diff[fi.ColName] = Change{Old: o, New: n}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,176 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type AuditedAccount struct {
+	Id      int64
+	Owner   string
+	Balance int64
+}
+
+func (AuditedAccount) Audited() {}
+
+type AuditedNoPK struct {
+	Name string
+}
+
+func (AuditedNoPK) Audited() {}
+
+func openAuditDB(t *testing.T, sink AuditSink) *DB {
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	sqldb.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqldb.Close() })
+	for _, ddl := range []string{
+		`CREATE TABLE audited_account (id INTEGER PRIMARY KEY, owner TEXT, balance INTEGER)`,
+		`CREATE TABLE audit_log (id INTEGER PRIMARY KEY, actor TEXT, table_name TEXT, op TEXT, pk TEXT, diff TEXT, created_at DATETIME)`,
+		`INSERT INTO audited_account (id, owner, balance) VALUES (1, 'tom', 100), (2, 'jerry', 200), (3, 'spike', 300)`,
+	} {
+		_, err = sqldb.Exec(ddl)
+		require.NoError(t, err)
+	}
+	return OpenDB(sqldb, WithDialect(SqliteDialect), WithAudit(sink))
+}
+
+func TestAudit(t *testing.T) {
+	db := openAuditDB(t, TableSink())
+	ctx := WithActor(context.Background(), "alice")
+
+	res := NewUpdater[AuditedAccount](db).Set(Assign("Balance", 50)).
+		Where(C("Id").LT(3)).Exec(&middleware.Context{Ctx: ctx})
+	require.NoError(t, res.Err())
+	d := NewDeletor[AuditedAccount](db)
+	d.Where(C("Id").Eq(3))
+	require.NoError(t, d.Exec(&middleware.Context{Ctx: ctx}).Err())
+	// rows left unchanged are not recorded
+	res = NewUpdater[AuditedAccount](db).Set(Assign("Balance", 50)).
+		Where(C("Id").Eq(1)).Exec(&middleware.Context{Ctx: ctx})
+	require.NoError(t, res.Err())
+
+	logs, err := NewSelector[AuditLog](db).GetMulti(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	type entry struct {
+		Actor, Table, Op, Pk, Diff string
+	}
+	got := make([]entry, 0, len(logs))
+	for _, l := range logs {
+		assert.False(t, l.CreatedAt.IsZero())
+		got = append(got, entry{Actor: l.Actor, Table: l.Table, Op: l.Op, Pk: l.Pk, Diff: l.Diff})
+	}
+	assert.Equal(t, []entry{
+		{Actor: "alice", Table: "audited_account", Op: "update", Pk: "1", Diff: `{"balance":{"old":100,"new":50}}`},
+		{Actor: "alice", Table: "audited_account", Op: "update", Pk: "2", Diff: `{"balance":{"old":200,"new":50}}`},
+		{Actor: "alice", Table: "audited_account", Op: "delete", Pk: "3",
+			Diff: `{"balance":{"old":300,"new":null},"id":{"old":3,"new":null},"owner":{"old":"spike","new":null}}`},
+	}, got)
+}
+
+func TestAudit_Rollback(t *testing.T) {
+	errSink := errors.New("sink down")
+	var records []AuditRecord
+	failing := false
+	db := openAuditDB(t, AuditSinkFunc(func(ctx context.Context, sess Session, rs []AuditRecord) error {
+		if failing {
+			return errSink
+		}
+		records = append(records, rs...)
+		return nil
+	}))
+	balanceOf := func(id int64) int64 {
+		acc, err := NewSelector[AuditedAccount](db).Where(C("Id").Eq(id)).Get(&middleware.Context{Ctx: context.Background()})
+		require.NoError(t, err)
+		return acc.Balance
+	}
+
+	// the change is rolled back when the records cannot be written
+	failing = true
+	res := NewUpdater[AuditedAccount](db).Set(Assign("Balance", 0)).
+		Where(C("Id").Eq(1)).Exec(&middleware.Context{Ctx: context.Background()})
+	assert.Equal(t, errSink, res.Err())
+	assert.Equal(t, int64(100), balanceOf(1))
+
+	// and with the transaction it joined
+	failing = false
+	errFn := errors.New("fn failed")
+	err := db.DoTx(context.Background(), func(ctx context.Context, tx *Transaction) error {
+		res := NewUpdater[AuditedAccount](tx).Set(Assign("Balance", 0)).
+			Where(C("Id").Eq(2)).Exec(&middleware.Context{Ctx: ctx})
+		require.NoError(t, res.Err())
+		return errFn
+	})
+	assert.Equal(t, errFn, err)
+	assert.Equal(t, int64(200), balanceOf(2))
+	require.Len(t, records, 1)
+	assert.Equal(t, AuditRecord{
+		Table: "audited_account",
+		Kind:  middleware.KindUpdate,
+		PK:    int64(2),
+		Diff:  map[string]Change{"balance": {Old: int64(200), New: int64(0)}},
+		At:    records[0].At,
+	}, records[0])
+}
+
+func TestAudit_AssignPK(t *testing.T) {
+	var records []AuditRecord
+	db := openAuditDB(t, AuditSinkFunc(func(ctx context.Context, sess Session, rs []AuditRecord) error {
+		records = append(records, rs...)
+		return nil
+	}))
+	ctx := &middleware.Context{Ctx: context.Background()}
+
+	// the row is read again by the primary key it is moved to
+	res := NewUpdater[AuditedAccount](db).Set(Assign("Id", 10), Assign("Balance", 0)).
+		Where(C("Id").Eq(1)).Exec(ctx)
+	require.NoError(t, res.Err())
+	// and by its own one when the struct assigns it again
+	res = NewUpdater[AuditedAccount](db).FromStruct(&AuditedAccount{Id: 2, Balance: 70}).
+		Where(C("Id").Eq(2)).Exec(ctx)
+	require.NoError(t, res.Err())
+
+	require.Len(t, records, 2)
+	assert.Equal(t, AuditRecord{
+		Table: "audited_account",
+		Kind:  middleware.KindUpdate,
+		PK:    int64(1),
+		Diff: map[string]Change{
+			"id":      {Old: int64(1), New: int64(10)},
+			"balance": {Old: int64(100), New: int64(0)},
+		},
+		At: records[0].At,
+	}, records[0])
+	assert.Equal(t, AuditRecord{
+		Table: "audited_account",
+		Kind:  middleware.KindUpdate,
+		PK:    int64(2),
+		Diff:  map[string]Change{"balance": {Old: int64(200), New: int64(70)}},
+		At:    records[1].At,
+	}, records[1])
+}
+
+func TestAudit_Unsupported(t *testing.T) {
+	db := openAuditDB(t, TableSink())
+	ctx := &middleware.Context{Ctx: context.Background()}
+
+	res := NewUpdater[AuditedNoPK](db).Set(Assign("Name", "x")).AllowFullTable().Exec(ctx)
+	assert.Equal(t, errs.ErrNoPrimaryKey, res.Err())
+
+	d := NewDeletor[AuditedAccount](db)
+	d.From("other_account")
+	d.Where(C("Id").Eq(1))
+	assert.Equal(t, errs.ErrUnsupported, d.Exec(ctx).Err())
+
+	cu, err := NewUpdater[AuditedAccount](db).Set(Assign("Balance", 0)).
+		Where(C("Id").Eq(Param("id"))).Compile()
+	require.NoError(t, err)
+	assert.Equal(t, errs.ErrUnsupported, cu.Exec(ctx, Bindings{"id": 1}).Err())
+}
@@ -5,8 +5,29 @@ import (
 	"github.com/kisara71/go-orm/middleware"
 )
 
+// Builder builds a statement without running it.
 type Builder interface {
-	Build(ctx *middleware.Context) error
+	Build() (*Query, error)
+	// BuildContext builds the statement for ctx, which carries e.g. the
+	// tenant of the tenant models.
+	BuildContext(ctx context.Context) (*Query, error)
+}
+
+// statementBuilder fills the middleware context of a call with its
+// statement, see core.run.
+type statementBuilder interface {
+	build(ctx *middleware.Context) error
+}
+
+func buildQuery(ctx context.Context, b statementBuilder) (*Query, error) {
+	mctx := &middleware.Context{Ctx: ctx}
+	if err := b.build(mctx); err != nil {
+		return nil, err
+	}
+	return &Query{
+		SQL:  mctx.Statement,
+		Args: mctx.Args,
+	}, nil
 }
 
 type Querier[T any] interface {
@@ -0,0 +1,115 @@
+package go_orm
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestBuilder_Clone checks that the clones of a builder and the builder
+// itself are changed independently: the clones are changed before any of
+// the builders is built.
+func TestBuilder_Clone(t *testing.T) {
+	type TestModel struct {
+		Id   int64
+		Name string
+		Age  int
+	}
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	selector := NewSelector[TestModel](db).Where(C("Id").GT(1)).OrderBy(ASC("Id"))
+	insertor := NewInsertor[TestModel](db).Values(&TestModel{Id: 1, Name: "tom"}).
+		OnConflict().Update(C("Name"))
+	updater := NewUpdater[TestModel](db).Set(Assign("Name", "tom")).Where(C("Id").GT(1))
+	deletor := NewDeletor[TestModel](db).Where(C("Id").GT(1))
+
+	testCases := []struct {
+		name      string
+		builder   Builder
+		wantQuery *Query
+	}{
+		{
+			name:    "selector",
+			builder: selector,
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` > ? ORDER BY `id` ASC;",
+				Args: []any{1},
+			},
+		},
+		{
+			name:    "selector clone",
+			builder: selector.Clone().AndWhere(C("Name").Eq("tom")).OrderBy(DESC("Name")).Limit(1),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`id` > ?) AND (`name` = ?) ORDER BY `id` ASC, `name` DESC LIMIT ?;",
+				Args: []any{1, "tom", int64(1)},
+			},
+		},
+		{
+			name:    "selector grouped clone",
+			builder: selector.Clone().AndWhere(C("Id").LT(10)).GroupBy(C("Name")).Having(C("Id").GT(2)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`id` > ?) AND (`id` < ?) GROUP BY `name` HAVING `id` > ? ORDER BY `id` ASC;",
+				Args: []any{1, 10, 2},
+			},
+		},
+		{
+			name:    "insertor",
+			builder: insertor,
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model` (`id`, `name`, `age`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`);",
+				Args: []any{int64(1), "tom", 0},
+			},
+		},
+		{
+			name: "insertor clone",
+			builder: insertor.Clone().Values(&TestModel{Id: 2, Name: "jerry"}).
+				OnConflict().Update(Assign("Name", "spike")),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model` (`id`, `name`, `age`) VALUES (?, ?, ?), (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = ?;",
+				Args: []any{int64(1), "tom", 0, int64(2), "jerry", 0, "spike"},
+			},
+		},
+		{
+			name:    "updater",
+			builder: updater,
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `name` = ? WHERE `id` > ?;",
+				Args: []any{"tom", 1},
+			},
+		},
+		{
+			name:    "updater clone",
+			builder: updater.Clone().Set(Assign("Age", 18)).Where(C("Id").LT(10)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `name` = ?, `age` = ? WHERE (`id` > ?) AND (`id` < ?);",
+				Args: []any{"tom", 18, 1, 10},
+			},
+		},
+		{
+			name:    "deletor",
+			builder: deletor,
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `id` > ?",
+				Args: []any{1},
+			},
+		},
+		{
+			name:    "deletor clone",
+			builder: deletor.Clone().AndWhere(C("Name").Eq("tom")),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE (`id` > ?) AND (`name` = ?)",
+				Args: []any{1, "tom"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.builder.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package go_orm
 import (
 	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/model"
+	"slices"
 	"strings"
 )
 
@@ -12,6 +13,16 @@ type builder struct {
 	args    []any
 	dialect Dialect
 	qte     byte
+	// table is the physical table of m picked by the sharding router
+	table string
+	// tables are the logical tables written by buildTable
+	tables []string
+	// argCols holds the column each of args is bound to, "" when unknown
+	argCols []string
+	// argCol is the column of the args being added, see bindColumn
+	argCol string
+	// stmt is the statement being built, for the sessions of OpenEvaluator
+	stmt Statement
 }
 
 func NewBuilder(m *model.Model, dialect Dialect) *builder {
@@ -29,7 +40,36 @@ func (b *builder) quote(name string) {
 	b.sb.WriteString(name)
 	b.sb.WriteByte(b.qte)
 }
+func (b *builder) buildTable(m *model.Model) {
+	b.addTable(m.TableName)
+	if m == b.m && b.table != "" {
+		b.quote(b.table)
+		return
+	}
+	b.quote(m.TableName)
+}
+
+func (b *builder) buildComment(comment string) {
+	if comment == "" {
+		return
+	}
+	b.sb.WriteString("/* ")
+	b.sb.WriteString(comment)
+	b.sb.WriteString(" */ ")
+}
+
+func (b *builder) addTable(name string) {
+	if !slices.Contains(b.tables, name) {
+		b.tables = append(b.tables, name)
+	}
+}
+
+// getSQL returns the statement, its ? numbered where the dialect numbers
+// the placeholders.
 func (b *builder) getSQL() string {
+	if b.dialect.numbered() {
+		return number(b.sb.String(), b.qte)
+	}
 	return b.sb.String()
 }
 func (b *builder) getArgs() []any {
@@ -82,7 +122,13 @@ func (b *builder) buildExpression(exp Expression, clause Clause) error {
 		if ok {
 			b.sb.WriteByte('(')
 		}
-		if err := b.buildExpression(t.right, clause); err != nil {
+		unbind := func() {}
+		if col, isCol := columnOf(t.left); isCol {
+			unbind = b.bindColumn(col.name)
+		}
+		err := b.buildExpression(t.right, clause)
+		unbind()
+		if err != nil {
 			return err
 		}
 		if ok {
@@ -93,6 +139,10 @@ func (b *builder) buildExpression(exp Expression, clause Clause) error {
 			return err
 		}
 		b.sb.WriteByte(' ')
+	case columnar:
+		return b.buildExpression(t.Column(), clause)
+	case tenantScope:
+		b.buildTenantScope(t)
 	case Arg:
 		b.sb.WriteByte('?')
 		b.addArgs(t.val)
@@ -131,4 +181,32 @@ func (b *builder) addArgs(arg ...any) {
 		return
 	}
 	b.args = append(b.args, arg...)
+	for range arg {
+		b.argCols = append(b.argCols, b.argCol)
+	}
+}
+
+// bindColumn makes the args added until the returned func is called be
+// recorded as bound to the column of the Go field name.
+func (b *builder) bindColumn(name string) func() {
+	if fd, ok := b.m.GoMap[name]; ok {
+		b.argCol = fd.ColName
+	}
+	return func() {
+		b.argCol = ""
+	}
+}
+
+func (b *builder) getArgColumns() []string {
+	return b.argCols
+}
+
+func columnOf(exp Expression) (Column, bool) {
+	switch t := exp.(type) {
+	case Column:
+		return t, true
+	case columnar:
+		return t.Column(), true
+	}
+	return Column{}, false
 }
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const modelSrc = `package model
+
+type User struct {
+	ID        int64 ` + "`orm:\"column=id_t\"`" + `
+	FirstName string
+}
+
+type Broken struct {
+	Name string ` + "`orm:\"column\"`" + `
+}
+
+type Base struct {
+	ID int64
+}
+
+type Address struct {
+	Street string
+}
+
+type Customer struct {
+	Base
+	*Address
+	Name string
+}
+
+type Twice struct {
+	Base
+	ID int64
+}
+
+type Item struct {
+	ID int64
+}
+`
+
+func TestGenerate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "model.go")
+	require.NoError(t, os.WriteFile(filename, []byte(modelSrc), 0o644))
+
+	testCases := []struct {
+		name    string
+		types   []string
+		wantSrc string
+		wantErr string
+	}{
+		{
+			name:  "tagged and default columns",
+			types: []string{"User"},
+			wantSrc: `// Code generated by go-orm-accessor. DO NOT EDIT.
+
+package model
+
+import (
+	"github.com/kisara71/go-orm/errs"
+)
+
+func (u *User) Scan(cols []string) ([]any, error) {
+	dest := make([]any, len(cols))
+	for idx, col := range cols {
+		switch col {
+		case "id_t":
+			dest[idx] = &u.ID
+		case "first_name":
+			dest[idx] = &u.FirstName
+		default:
+			return nil, errs.ErrUnknownColumn
+		}
+	}
+	return dest, nil
+}
+
+func (u *User) Values(fields []string) ([]any, error) {
+	vals := make([]any, len(fields))
+	for idx, field := range fields {
+		switch field {
+		case "ID":
+			vals[idx] = u.ID
+		case "FirstName":
+			vals[idx] = u.FirstName
+		default:
+			return nil, errs.ErrUnknownField
+		}
+	}
+	return vals, nil
+}
+`,
+		},
+		{
+			name:    "invalid tag",
+			types:   []string{"Broken"},
+			wantErr: "go-orm-accessor: Broken.Name: invalid tags, check structure's tags",
+		},
+		{
+			name:  "embedded",
+			types: []string{"Customer"},
+			wantSrc: `// Code generated by go-orm-accessor. DO NOT EDIT.
+
+package model
+
+import (
+	"github.com/kisara71/go-orm/errs"
+)
+
+func (c *Customer) Scan(cols []string) ([]any, error) {
+	dest := make([]any, len(cols))
+	for idx, col := range cols {
+		switch col {
+		case "id":
+			dest[idx] = &c.Base.ID
+		case "street":
+			if c.Address == nil {
+				c.Address = new(Address)
+			}
+			dest[idx] = &c.Address.Street
+		case "name":
+			dest[idx] = &c.Name
+		default:
+			return nil, errs.ErrUnknownColumn
+		}
+	}
+	return dest, nil
+}
+
+func (c *Customer) Values(fields []string) ([]any, error) {
+	vals := make([]any, len(fields))
+	for idx, field := range fields {
+		switch field {
+		case "ID":
+			vals[idx] = c.Base.ID
+		case "Street":
+			if c.Address == nil {
+				vals[idx] = Address{}.Street
+			} else {
+				vals[idx] = c.Address.Street
+			}
+		case "Name":
+			vals[idx] = c.Name
+		default:
+			return nil, errs.ErrUnknownField
+		}
+	}
+	return vals, nil
+}
+`,
+		},
+		{
+			// the receiver i is not shadowed by the loop index
+			name:  "receiver i",
+			types: []string{"Item"},
+			wantSrc: `// Code generated by go-orm-accessor. DO NOT EDIT.
+
+package model
+
+import (
+	"github.com/kisara71/go-orm/errs"
+)
+
+func (i *Item) Scan(cols []string) ([]any, error) {
+	dest := make([]any, len(cols))
+	for idx, col := range cols {
+		switch col {
+		case "id":
+			dest[idx] = &i.ID
+		default:
+			return nil, errs.ErrUnknownColumn
+		}
+	}
+	return dest, nil
+}
+
+func (i *Item) Values(fields []string) ([]any, error) {
+	vals := make([]any, len(fields))
+	for idx, field := range fields {
+		switch field {
+		case "ID":
+			vals[idx] = i.ID
+		default:
+			return nil, errs.ErrUnknownField
+		}
+	}
+	return vals, nil
+}
+`,
+		},
+		{
+			name:    "defined twice",
+			types:   []string{"Twice"},
+			wantErr: "go-orm-accessor: Twice.ID: field or column defined twice",
+		},
+		{
+			name:    "missing type",
+			types:   []string{"Product"},
+			wantErr: "go-orm-accessor: struct types not found: Product",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := generate([]string{filename}, tc.types)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSrc, string(src))
+		})
+	}
+}
@@ -0,0 +1,16 @@
+// Command go-orm-accessor generates reflection free Scan and Values methods
+// for go-orm models. It is meant to be run through go generate:
+//
+//	//go:generate go run github.com/kisara71/go-orm/cmd/go-orm-accessor -type User,Order
+//
+// Models carrying these methods implement orm.ModelAccessor, which Selector
+// and Insertor use instead of the reflection based UnsafeAccessor.
+package main
+
+import (
+	"github.com/kisara71/go-orm/internal/codegen"
+)
+
+func main() {
+	codegen.Main("go-orm-accessor", "_accessor_gen.go", generate)
+}
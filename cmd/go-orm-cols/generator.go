@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kisara71/go-orm/internal/codegen"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"sort"
+	"strconv"
+	"text/template"
+)
+
+const ormImport = "github.com/kisara71/go-orm"
+
+type colDef struct {
+	Name string
+	Type string
+}
+
+type modelDef struct {
+	Name string
+	Cols []colDef
+}
+
+type fileDef struct {
+	Package string
+	Imports []string
+	Models  []modelDef
+}
+
+var fileTpl = template.Must(template.New("cols").Parse(`// Code generated by go-orm-cols. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range .Models}}
+var {{.Name}}Cols = struct {
+{{- range .Cols}}
+	{{.Name}} orm.TypedColumn[{{.Type}}]
+{{- end}}
+}{
+{{- range .Cols}}
+	{{.Name}}: orm.Col[{{.Type}}]("{{.Name}}"),
+{{- end}}
+}
+{{end}}`))
+
+// generate parses the given Go source files and emits typed column
+// descriptors for the requested struct types, or for every struct type when
+// names is empty.
+func generate(filenames []string, names []string) ([]byte, error) {
+	pkg, err := codegen.Parse("go-orm-cols", filenames, names)
+	if err != nil {
+		return nil, err
+	}
+	file := fileDef{Package: pkg.Name}
+	imports := map[string]struct{}{
+		"orm " + strconv.Quote(ormImport): {},
+	}
+	for _, s := range pkg.Structs {
+		m, err := modelOf(pkg, s, imports)
+		if err != nil {
+			return nil, err
+		}
+		file.Models = append(file.Models, m)
+	}
+	for imp := range imports {
+		file.Imports = append(file.Imports, imp)
+	}
+	sort.Slice(file.Imports, func(i, j int) bool {
+		return codegen.ImportPath(file.Imports[i]) < codegen.ImportPath(file.Imports[j])
+	})
+
+	var buf bytes.Buffer
+	if err := fileTpl.Execute(&buf, file); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func modelOf(pkg *codegen.Package, s *codegen.Struct, imports map[string]struct{}) (modelDef, error) {
+	fields, err := pkg.Fields(s)
+	if err != nil {
+		return modelDef{}, err
+	}
+	m := modelDef{Name: s.Name, Cols: make([]colDef, 0, len(fields))}
+	for _, field := range fields {
+		ast.Inspect(field.Type, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			ident, ok := sel.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			spec, ok, ierr := pkg.Import(field.Owner, ident.Name)
+			if ierr != nil {
+				err = ierr
+				return false
+			}
+			if !ok {
+				err = fmt.Errorf("go-orm-cols: %s.%s uses unknown package %s", s.Name, field.Name, ident.Name)
+				return false
+			}
+			imports[spec] = struct{}{}
+			return false
+		})
+		if err != nil {
+			return m, err
+		}
+		m.Cols = append(m.Cols, colDef{Name: field.Name, Type: types.ExprString(field.Type)})
+	}
+	return m, nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const modelSrc = `package model
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"example.com/m/go-foo"
+	"example.com/m/yaml.v3"
+	t "time"
+)
+
+type User struct {
+	ID        int64 ` + "`orm:\"column=id\"`" + `
+	FirstName string
+	Nick      sql.NullString
+	CreatedAt t.Time
+	Tags      []string
+}
+
+type Order struct {
+	ID, UserID int64
+}
+
+type Audit struct {
+	UpdatedAt t.Time
+}
+
+type Product struct {
+	*Audit
+	ID int64
+}
+
+type Config struct {
+	Bar  foo.Bar
+	Node *yaml.Node
+}
+
+type Money struct {
+	Cents int64
+}
+
+func (m *Money) Scan(src any) error { return nil }
+
+func (m Money) Value() (driver.Value, error) { return m.Cents, nil }
+
+// Stamp has the Scan method generated by go-orm-accessor, not the one of
+// sql.Scanner.
+type Stamp struct {
+	StampedAt t.Time
+}
+
+func (s *Stamp) Scan(cols []string) ([]any, error) { return nil, nil }
+
+type Invoice struct {
+	Money
+	*Stamp
+	sql.NullString
+	ID int64
+}
+
+type Remote struct {
+	foo.Bar
+	ID int64
+}
+
+type TaggedRemote struct {
+	foo.Bar ` + "`orm:\"column=bar\"`" + `
+	ID int64
+}
+`
+
+// packages are imported by modelSrc under names which differ from the last
+// element of their path.
+var packages = map[string]string{
+	"go.mod":          "module example.com/m\n\ngo 1.23\n",
+	"go-foo/foo.go":   "package foo\n\ntype Bar int\n",
+	"yaml.v3/yaml.go": "package yaml\n\ntype Node struct{}\n",
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range packages {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644))
+	}
+	filename := filepath.Join(dir, "model.go")
+	require.NoError(t, os.WriteFile(filename, []byte(modelSrc), 0o644))
+
+	testCases := []struct {
+		name    string
+		types   []string
+		wantSrc string
+		wantErr string
+	}{
+		{
+			name:  "selected type",
+			types: []string{"User"},
+			wantSrc: `// Code generated by go-orm-cols. DO NOT EDIT.
+
+package model
+
+import (
+	"database/sql"
+	orm "github.com/kisara71/go-orm"
+	t "time"
+)
+
+var UserCols = struct {
+	ID        orm.TypedColumn[int64]
+	FirstName orm.TypedColumn[string]
+	Nick      orm.TypedColumn[sql.NullString]
+	CreatedAt orm.TypedColumn[t.Time]
+	Tags      orm.TypedColumn[[]string]
+}{
+	ID:        orm.Col[int64]("ID"),
+	FirstName: orm.Col[string]("FirstName"),
+	Nick:      orm.Col[sql.NullString]("Nick"),
+	CreatedAt: orm.Col[t.Time]("CreatedAt"),
+	Tags:      orm.Col[[]string]("Tags"),
+}
+`,
+		},
+		{
+			name:  "grouped field names",
+			types: []string{"Order"},
+			wantSrc: `// Code generated by go-orm-cols. DO NOT EDIT.
+
+package model
+
+import (
+	orm "github.com/kisara71/go-orm"
+)
+
+var OrderCols = struct {
+	ID     orm.TypedColumn[int64]
+	UserID orm.TypedColumn[int64]
+}{
+	ID:     orm.Col[int64]("ID"),
+	UserID: orm.Col[int64]("UserID"),
+}
+`,
+		},
+		{
+			name:  "embedded",
+			types: []string{"Product"},
+			wantSrc: `// Code generated by go-orm-cols. DO NOT EDIT.
+
+package model
+
+import (
+	orm "github.com/kisara71/go-orm"
+	t "time"
+)
+
+var ProductCols = struct {
+	UpdatedAt orm.TypedColumn[t.Time]
+	ID        orm.TypedColumn[int64]
+}{
+	UpdatedAt: orm.Col[t.Time]("UpdatedAt"),
+	ID:        orm.Col[int64]("ID"),
+}
+`,
+		},
+		{
+			name:  "package names",
+			types: []string{"Config"},
+			wantSrc: `// Code generated by go-orm-cols. DO NOT EDIT.
+
+package model
+
+import (
+	"example.com/m/go-foo"
+	"example.com/m/yaml.v3"
+	orm "github.com/kisara71/go-orm"
+)
+
+var ConfigCols = struct {
+	Bar  orm.TypedColumn[foo.Bar]
+	Node orm.TypedColumn[*yaml.Node]
+}{
+	Bar:  orm.Col[foo.Bar]("Bar"),
+	Node: orm.Col[*yaml.Node]("Node"),
+}
+`,
+		},
+		{
+			name:  "embedded columns",
+			types: []string{"Invoice"},
+			wantSrc: `// Code generated by go-orm-cols. DO NOT EDIT.
+
+package model
+
+import (
+	"database/sql"
+	orm "github.com/kisara71/go-orm"
+	t "time"
+)
+
+var InvoiceCols = struct {
+	Money      orm.TypedColumn[Money]
+	StampedAt  orm.TypedColumn[t.Time]
+	NullString orm.TypedColumn[sql.NullString]
+	ID         orm.TypedColumn[int64]
+}{
+	Money:      orm.Col[Money]("Money"),
+	StampedAt:  orm.Col[t.Time]("StampedAt"),
+	NullString: orm.Col[sql.NullString]("NullString"),
+	ID:         orm.Col[int64]("ID"),
+}
+`,
+		},
+		{
+			name:    "embedded of another package",
+			types:   []string{"Remote"},
+			wantErr: "go-orm-cols: Remote.Bar: embedded type of another package, tag it to read it as a column",
+		},
+		{
+			name:  "tagged embedded of another package",
+			types: []string{"TaggedRemote"},
+			wantSrc: `// Code generated by go-orm-cols. DO NOT EDIT.
+
+package model
+
+import (
+	"example.com/m/go-foo"
+	orm "github.com/kisara71/go-orm"
+)
+
+var TaggedRemoteCols = struct {
+	Bar orm.TypedColumn[foo.Bar]
+	ID  orm.TypedColumn[int64]
+}{
+	Bar: orm.Col[foo.Bar]("Bar"),
+	ID:  orm.Col[int64]("ID"),
+}
+`,
+		},
+		{
+			name:    "missing type",
+			types:   []string{"Item"},
+			wantErr: "go-orm-cols: struct types not found: Item",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := generate([]string{filename}, tc.types)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSrc, string(src))
+		})
+	}
+}
@@ -0,0 +1,16 @@
+// Command go-orm-cols generates typed column descriptors for go-orm models.
+// It is meant to be run through go generate:
+//
+//	//go:generate go run github.com/kisara71/go-orm/cmd/go-orm-cols -type User,Order
+//
+// and emits, for each struct, a variable such as UserCols whose fields are
+// orm.TypedColumn values named after the struct fields.
+package main
+
+import (
+	"github.com/kisara71/go-orm/internal/codegen"
+)
+
+func main() {
+	codegen.Main("go-orm-cols", "_cols_gen.go", generate)
+}
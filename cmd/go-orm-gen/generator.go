@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kisara71/go-orm/utils"
+	"go/format"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+type tableFilter struct {
+	include []string
+	exclude []string
+}
+
+func (f tableFilter) match(table string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, table) {
+		return false
+	}
+	return !matchAny(f.exclude, table)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// naming maps tables and columns to Go identifiers. Overrides are keyed by
+// "table" for struct names and "table.column" for field names.
+type naming struct {
+	overrides map[string]string
+}
+
+func parseNaming(spec string) (naming, error) {
+	n := naming{overrides: make(map[string]string, 4)}
+	for _, pair := range splitList(spec) {
+		seg := strings.SplitN(pair, "=", 2)
+		if len(seg) != 2 || seg[0] == "" || seg[1] == "" {
+			return n, fmt.Errorf("go-orm-gen: invalid rename %q, expected table=Name or table.column=Name", pair)
+		}
+		n.overrides[seg[0]] = seg[1]
+	}
+	return n, nil
+}
+
+func (n naming) structName(table string) string {
+	if name, ok := n.overrides[table]; ok {
+		return name
+	}
+	return utils.SnakeToCamel(table)
+}
+
+func (n naming) fieldName(table, column string) string {
+	if name, ok := n.overrides[table+"."+column]; ok {
+		return name
+	}
+	name := utils.SnakeToCamel(column)
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		name = "F" + name
+	}
+	return name
+}
+
+type fieldDef struct {
+	Name   string
+	Type   string
+	Column string
+}
+
+type modelDef struct {
+	Name   string
+	Table  string
+	Fields []fieldDef
+}
+
+type fileDef struct {
+	Package string
+	Imports []string
+	Models  []modelDef
+}
+
+var fileTpl = template.Must(template.New("models").Parse(`// Code generated by go-orm-gen. DO NOT EDIT.
+
+package {{.Package}}
+{{if .Imports}}
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{end}}
+{{- range .Models}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`" + `orm:"column={{.Column}}"` + "`" + `
+{{- end}}
+}
+
+func (*{{.Name}}) TableName() string {
+	return "{{.Table}}"
+}
+{{end}}`))
+
+func generate(pkg string, tables []tableInfo, n naming) ([]byte, error) {
+	imports := make(map[string]struct{}, 2)
+	file := fileDef{
+		Package: pkg,
+		Models:  make([]modelDef, 0, len(tables)),
+	}
+	for _, t := range tables {
+		m := modelDef{
+			Name:   n.structName(t.Name),
+			Table:  t.Name,
+			Fields: make([]fieldDef, 0, len(t.Columns)),
+		}
+		for _, col := range t.Columns {
+			typ, imp := goType(col)
+			if imp != "" {
+				imports[imp] = struct{}{}
+			}
+			m.Fields = append(m.Fields, fieldDef{
+				Name:   n.fieldName(t.Name, col.Name),
+				Type:   typ,
+				Column: col.Name,
+			})
+		}
+		file.Models = append(file.Models, m)
+	}
+	for imp := range imports {
+		file.Imports = append(file.Imports, imp)
+	}
+	sort.Strings(file.Imports)
+
+	var buf bytes.Buffer
+	if err := fileTpl.Execute(&buf, file); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// goType maps a database column type to a Go type by its base type, the
+// first word of the type without its length and sign, e.g. bigint for
+// "bigint(20) unsigned" or character for "character varying". MySQL's
+// tinyint(1) is a bool, the unknown types are read as strings.
+func goType(col columnInfo) (string, string) {
+	typ := strings.ToLower(col.Type)
+	switch base, size := baseType(typ); base {
+	case "bool", "boolean":
+		return nullable(col, "bool", "sql.NullBool"), importFor(col, "")
+	case "tinyint":
+		if size == "1" {
+			return nullable(col, "bool", "sql.NullBool"), importFor(col, "")
+		}
+		return nullable(col, "int64", "sql.NullInt64"), importFor(col, "")
+	case "int", "integer", "smallint", "mediumint", "bigint", "int2", "int4", "int8",
+		"serial", "smallserial", "bigserial", "year":
+		return nullable(col, "int64", "sql.NullInt64"), importFor(col, "")
+	case "real", "float", "float4", "float8", "double":
+		return nullable(col, "float64", "sql.NullFloat64"), importFor(col, "")
+	case "date", "datetime", "timestamp", "timestamptz", "time", "timetz":
+		if col.Nullable {
+			return "sql.NullTime", "database/sql"
+		}
+		return "time.Time", "time"
+	case "", "blob", "tinyblob", "mediumblob", "longblob", "binary", "varbinary", "bytea":
+		return "[]byte", ""
+	}
+	return nullable(col, "string", "sql.NullString"), importFor(col, "")
+}
+
+// baseType returns the first word of typ, a lower case column type, which
+// is not a sign, and the size given in parentheses.
+func baseType(typ string) (base, size string) {
+	if before, after, ok := strings.Cut(typ, "("); ok {
+		size, after, _ = strings.Cut(after, ")")
+		typ = before + " " + after
+	}
+	for _, word := range strings.Fields(typ) {
+		if word != "unsigned" && word != "signed" {
+			return word, strings.TrimSpace(size)
+		}
+	}
+	return "", strings.TrimSpace(size)
+}
+
+func nullable(col columnInfo, typ, nullTyp string) string {
+	if col.Nullable {
+		return nullTyp
+	}
+	return typ
+}
+
+func importFor(col columnInfo, imp string) string {
+	if col.Nullable {
+		return "database/sql"
+	}
+	return imp
+}
+
+func splitList(s string) []string {
+	res := make([]string, 0, 4)
+	for _, seg := range strings.Split(s, ",") {
+		if seg = strings.TrimSpace(seg); seg != "" {
+			res = append(res, seg)
+		}
+	}
+	return res
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func openFixture(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "fixture.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.Exec(`
+CREATE TABLE user_account (
+	id INTEGER PRIMARY KEY,
+	first_name VARCHAR(64) NOT NULL,
+	nick_name TEXT,
+	balance REAL NOT NULL,
+	score DOUBLE,
+	active BOOLEAN NOT NULL,
+	avatar BLOB,
+	created_at DATETIME NOT NULL,
+	deleted_at DATETIME
+);
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY,
+	message TEXT NOT NULL
+);`)
+	require.NoError(t, err)
+	return db
+}
+
+func TestGenerate(t *testing.T) {
+	db := openFixture(t)
+
+	testCases := []struct {
+		name    string
+		filter  tableFilter
+		rename  string
+		wantSrc string
+	}{
+		{
+			name:   "single table",
+			filter: tableFilter{include: []string{"user_*"}},
+			wantSrc: `// Code generated by go-orm-gen. DO NOT EDIT.
+
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type UserAccount struct {
+	ID        int64           ` + "`orm:\"column=id\"`" + `
+	FirstName string          ` + "`orm:\"column=first_name\"`" + `
+	NickName  sql.NullString  ` + "`orm:\"column=nick_name\"`" + `
+	Balance   float64         ` + "`orm:\"column=balance\"`" + `
+	Score     sql.NullFloat64 ` + "`orm:\"column=score\"`" + `
+	Active    bool            ` + "`orm:\"column=active\"`" + `
+	Avatar    []byte          ` + "`orm:\"column=avatar\"`" + `
+	CreatedAt time.Time       ` + "`orm:\"column=created_at\"`" + `
+	DeletedAt sql.NullTime    ` + "`orm:\"column=deleted_at\"`" + `
+}
+
+func (*UserAccount) TableName() string {
+	return "user_account"
+}
+`,
+		},
+		{
+			name:   "exclude and rename",
+			filter: tableFilter{exclude: []string{"user_account"}},
+			rename: "audit_log=Audit,audit_log.message=Text",
+			wantSrc: `// Code generated by go-orm-gen. DO NOT EDIT.
+
+package model
+
+type Audit struct {
+	ID   int64  ` + "`orm:\"column=id\"`" + `
+	Text string ` + "`orm:\"column=message\"`" + `
+}
+
+func (*Audit) TableName() string {
+	return "audit_log"
+}
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := parseNaming(tc.rename)
+			require.NoError(t, err)
+			reader, err := newSchemaReader("sqlite3", db)
+			require.NoError(t, err)
+			tables, err := readSchema(context.Background(), reader, tc.filter)
+			require.NoError(t, err)
+			src, err := generate("model", tables, n)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSrc, string(src))
+		})
+	}
+}
+
+func TestParseNaming(t *testing.T) {
+	_, err := parseNaming("users")
+	assert.Error(t, err)
+	n, err := parseNaming("users=Account, users.uid=UserID")
+	require.NoError(t, err)
+	assert.Equal(t, "Account", n.structName("users"))
+	assert.Equal(t, "UserID", n.fieldName("users", "uid"))
+	assert.Equal(t, "CreatedAt", n.fieldName("users", "created_at"))
+}
+
+func TestGoType(t *testing.T) {
+	testCases := []struct {
+		typ        string
+		nullable   bool
+		wantType   string
+		wantImport string
+	}{
+		{typ: "INTEGER", wantType: "int64"},
+		{typ: "bigint(20) unsigned", wantType: "int64"},
+		{typ: "int4", nullable: true, wantType: "sql.NullInt64", wantImport: "database/sql"},
+		{typ: "tinyint(1)", wantType: "bool"},
+		{typ: "tinyint(4)", wantType: "int64"},
+		{typ: "boolean", nullable: true, wantType: "sql.NullBool", wantImport: "database/sql"},
+		{typ: "point", wantType: "string"},
+		{typ: "interval", wantType: "string"},
+		{typ: "character varying", wantType: "string"},
+		{typ: "decimal(10,2)", wantType: "string"},
+		{typ: "double precision", wantType: "float64"},
+		{typ: "REAL", nullable: true, wantType: "sql.NullFloat64", wantImport: "database/sql"},
+		{typ: "timestamp without time zone", wantType: "time.Time", wantImport: "time"},
+		{typ: "DATETIME", nullable: true, wantType: "sql.NullTime", wantImport: "database/sql"},
+		{typ: "bytea", wantType: "[]byte"},
+		{typ: "", wantType: "[]byte"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.typ, func(t *testing.T) {
+			typ, imp := goType(columnInfo{Type: tc.typ, Nullable: tc.nullable})
+			assert.Equal(t, tc.wantType, typ)
+			assert.Equal(t, tc.wantImport, imp)
+		})
+	}
+}
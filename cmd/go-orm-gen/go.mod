@@ -0,0 +1,21 @@
+module github.com/kisara71/go-orm/cmd/go-orm-gen
+
+go 1.23.7
+
+require (
+	github.com/go-sql-driver/mysql v1.8.1
+	github.com/kisara71/go-orm v0.0.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/stretchr/testify v1.10.0
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// the command is built against the library of the same revision
+replace github.com/kisara71/go-orm => ../..
@@ -0,0 +1,71 @@
+// Command go-orm-gen reads the schema of an existing database and writes Go
+// models usable with go-orm.
+//
+//	go-orm-gen -driver sqlite3 -dsn ./app.db -pkg model -out model/models.go
+//
+// It is a module of its own, so that the database drivers it links are not
+// requirements of the library, built from this directory with go install.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"os"
+)
+
+func main() {
+	var (
+		driver  = flag.String("driver", "sqlite3", "database driver: sqlite3, mysql or postgres")
+		dsn     = flag.String("dsn", "", "data source name")
+		pkg     = flag.String("pkg", "model", "package name of the generated file")
+		out     = flag.String("out", "", "output file, stdout when empty")
+		tables  = flag.String("tables", "", "comma separated table patterns to include")
+		exclude = flag.String("exclude", "", "comma separated table patterns to exclude")
+		rename  = flag.String("rename", "", "comma separated overrides, table=Struct or table.column=Field")
+	)
+	flag.Parse()
+	if err := run(*driver, *dsn, *pkg, *out, *tables, *exclude, *rename); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(driver, dsn, pkg, out, tables, exclude, rename string) error {
+	if dsn == "" {
+		return fmt.Errorf("go-orm-gen: -dsn is required")
+	}
+	n, err := parseNaming(rename)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	reader, err := newSchemaReader(driver, db)
+	if err != nil {
+		return err
+	}
+	infos, err := readSchema(context.Background(), reader, tableFilter{
+		include: splitList(tables),
+		exclude: splitList(exclude),
+	})
+	if err != nil {
+		return err
+	}
+	src, err := generate(pkg, infos, n)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type columnInfo struct {
+	Name       string
+	Type       string
+	Nullable   bool
+	PrimaryKey bool
+}
+
+type tableInfo struct {
+	Name    string
+	Columns []columnInfo
+}
+
+type schemaReader interface {
+	Tables(ctx context.Context) ([]string, error)
+	Columns(ctx context.Context, table string) ([]columnInfo, error)
+}
+
+func newSchemaReader(driver string, db *sql.DB) (schemaReader, error) {
+	switch driver {
+	case "sqlite3", "sqlite":
+		return &sqliteReader{db: db}, nil
+	case "mysql":
+		return &mysqlReader{db: db}, nil
+	case "postgres", "pgx":
+		return &postgresReader{db: db}, nil
+	}
+	return nil, fmt.Errorf("go-orm-gen: unsupported driver %q", driver)
+}
+
+func readSchema(ctx context.Context, reader schemaReader, filter tableFilter) ([]tableInfo, error) {
+	names, err := reader.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]tableInfo, 0, len(names))
+	for _, name := range names {
+		if !filter.match(name) {
+			continue
+		}
+		cols, err := reader.Columns(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, tableInfo{Name: name, Columns: cols})
+	}
+	return tables, nil
+}
+
+func queryStrings(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]string, 0, 16)
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+type sqliteReader struct {
+	db *sql.DB
+}
+
+func (s *sqliteReader) Tables(ctx context.Context) ([]string, error) {
+	return queryStrings(ctx, s.db,
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+}
+
+func (s *sqliteReader) Columns(ctx context.Context, table string) ([]columnInfo, error) {
+	rows, err := s.db.QueryContext(ctx,
+		fmt.Sprintf(`PRAGMA table_info("%s")`, strings.ReplaceAll(table, `"`, `""`)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := make([]columnInfo, 0, 16)
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			typ     string
+			notNull bool
+			dflt    sql.NullString
+			pk      int
+		)
+		if err = rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		cols = append(cols, columnInfo{
+			Name:       name,
+			Type:       typ,
+			Nullable:   !notNull && pk == 0,
+			PrimaryKey: pk > 0,
+		})
+	}
+	return cols, rows.Err()
+}
+
+type mysqlReader struct {
+	db *sql.DB
+}
+
+func (m *mysqlReader) Tables(ctx context.Context) ([]string, error) {
+	return queryStrings(ctx, m.db,
+		"SELECT TABLE_NAME FROM information_schema.TABLES "+
+			"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME")
+}
+
+func (m *mysqlReader) Columns(ctx context.Context, table string) ([]columnInfo, error) {
+	return scanInformationSchema(ctx, m.db,
+		"SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY FROM information_schema.COLUMNS "+
+			"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", table)
+}
+
+type postgresReader struct {
+	db *sql.DB
+}
+
+func (p *postgresReader) Tables(ctx context.Context) ([]string, error) {
+	return queryStrings(ctx, p.db,
+		"SELECT table_name FROM information_schema.tables "+
+			"WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name")
+}
+
+func (p *postgresReader) Columns(ctx context.Context, table string) ([]columnInfo, error) {
+	return scanInformationSchema(ctx, p.db,
+		"SELECT c.column_name, c.data_type, c.is_nullable, "+
+			"CASE WHEN EXISTS (SELECT 1 FROM information_schema.table_constraints tc "+
+			"JOIN information_schema.key_column_usage k ON tc.constraint_name = k.constraint_name "+
+			"AND tc.table_schema = k.table_schema "+
+			"WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema "+
+			"AND tc.table_name = c.table_name AND k.column_name = c.column_name) THEN 'PRI' ELSE '' END "+
+			"FROM information_schema.columns c "+
+			"WHERE c.table_schema = current_schema() AND c.table_name = $1 ORDER BY c.ordinal_position", table)
+}
+
+func scanInformationSchema(ctx context.Context, db *sql.DB, query string, table string) ([]columnInfo, error) {
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := make([]columnInfo, 0, 16)
+	for rows.Next() {
+		var name, typ, nullable, key string
+		if err = rows.Scan(&name, &typ, &nullable, &key); err != nil {
+			return nil, err
+		}
+		cols = append(cols, columnInfo{
+			Name:       name,
+			Type:       typ,
+			Nullable:   strings.EqualFold(nullable, "YES"),
+			PrimaryKey: key == "PRI",
+		})
+	}
+	return cols, rows.Err()
+}
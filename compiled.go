@@ -0,0 +1,221 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/model"
+)
+
+// Parameter is a named placeholder, used in place of a value when building
+// a query that is compiled once and executed with different Bindings.
+type Parameter struct {
+	name string
+}
+
+func Param(name string) Parameter {
+	return Parameter{name: name}
+}
+
+// Bindings holds the values of the Parameters of a compiled query.
+type Bindings map[string]any
+
+// compiledQuery is a statement built once, whose Parameters are replaced
+// by their bound values on every execution.
+type compiledQuery struct {
+	model  *model.Model
+	kind   middleware.OpKind
+	tables []string
+	sql    string
+	args   []any
+	cols   []string
+	params map[int]string
+	// where, limit and full are the HasWhere, HasLimit and FullTable of
+	// the built statement
+	where bool
+	limit bool
+	full  bool
+	// unscoped is the statement run under WithoutTenant, nil when the model
+	// has no tenant
+	unscoped *compiledQuery
+}
+
+func compile(b statementBuilder) (compiledQuery, error) {
+	// the tenant, if any, is bound on every execution
+	q, err := compileFor(b, WithTenant(context.Background(), Param(tenantParam)))
+	if err != nil || q.model.Tenant == nil {
+		return q, err
+	}
+	unscoped, err := compileFor(b, WithoutTenant(context.Background()))
+	if err != nil {
+		return compiledQuery{}, err
+	}
+	q.unscoped = &unscoped
+	return q, nil
+}
+
+func compileFor(b statementBuilder, c context.Context) (compiledQuery, error) {
+	ctx := &middleware.Context{Ctx: c}
+	if err := b.build(ctx); err != nil {
+		return compiledQuery{}, err
+	}
+	q := compiledQuery{
+		model:  ctx.Model,
+		kind:   ctx.Kind,
+		tables: ctx.Tables,
+		sql:    ctx.Statement,
+		args:   ctx.Args,
+		cols:   ctx.ArgColumns,
+		where:  ctx.HasWhere,
+		limit:  ctx.HasLimit,
+		full:   ctx.FullTable,
+		params: make(map[int]string, 4),
+	}
+	for idx, arg := range ctx.Args {
+		if p, ok := arg.(Parameter); ok {
+			q.params[idx] = p.name
+		}
+	}
+	return q, nil
+}
+
+// bind sets the statement and the bound arguments on ctx, the tenant is
+// read from ctx as for the builders.
+func (q compiledQuery) bind(ctx *middleware.Context, binds Bindings) error {
+	tenant, scoped, err := tenantOf(ctx.Ctx, q.model)
+	if err != nil {
+		return err
+	}
+	if !scoped && q.unscoped != nil {
+		return q.unscoped.bind(ctx, binds)
+	}
+	args := q.args
+	if len(q.params) > 0 {
+		args = make([]any, len(q.args))
+		copy(args, q.args)
+		for idx, name := range q.params {
+			if name == tenantParam {
+				args[idx] = tenant
+				continue
+			}
+			val, ok := binds[name]
+			if !ok {
+				return errs.ErrUnboundParam
+			}
+			args[idx] = val
+		}
+	}
+	ctx.Model = q.model
+	ctx.Kind = q.kind
+	ctx.Tables = q.tables
+	ctx.SetStatement(q.sql)
+	ctx.SetArgs(args)
+	ctx.ArgColumns = q.cols
+	ctx.HasWhere, ctx.HasLimit, ctx.FullTable = q.where, q.limit, q.full
+	return nil
+}
+
+// CompiledSelector is a Selector whose SQL was built once. It is safe for
+// concurrent use, later changes to the Selector it was compiled from do not
+// affect it.
+type CompiledSelector[T any] struct {
+	s *Selector[T]
+	q compiledQuery
+}
+
+// Compile builds the selector once. Sharded models are not supported since
+// their statement depends on the shard key.
+// The tenant of scoped models is read from the context of every execution.
+func (s *Selector[T]) Compile() (*CompiledSelector[T], error) {
+	if _, ok := shardingRuleOf[T](); ok {
+		return nil, errs.ErrUnsupported
+	}
+	s = s.Clone()
+	q, err := compile(s)
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledSelector[T]{s: s, q: q}, nil
+}
+
+func (c *CompiledSelector[T]) Get(ctx *middleware.Context, binds Bindings) (*T, error) {
+	ctx.Type = middleware.OpQuery
+	ctx.Single = true
+	if err := c.q.bind(ctx, binds); err != nil {
+		return nil, err
+	}
+	res := c.s.core.run(ctx, c.s.opts, c.s.fetchOne)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return res.Res.(*T), nil
+}
+
+func (c *CompiledSelector[T]) GetMulti(ctx *middleware.Context, binds Bindings) ([]*T, error) {
+	ctx.Type = middleware.OpQuery
+	if err := c.q.bind(ctx, binds); err != nil {
+		return nil, err
+	}
+	res := c.s.core.run(ctx, c.s.opts, c.s.handlerMulti)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return res.Res.([]*T), nil
+}
+
+// CompiledUpdater is an Updater whose SQL was built once. The BeforeUpdate
+// and AfterUpdate hooks of the FromStruct entity run on a copy of it on every
+// execution: they are read-only, the statement is built already.
+type CompiledUpdater[T any] struct {
+	u *Updater[T]
+	q compiledQuery
+}
+
+func (u *Updater[T]) Compile() (*CompiledUpdater[T], error) {
+	if _, ok := shardingRuleOf[T](); ok {
+		return nil, errs.ErrUnsupported
+	}
+	u = u.Clone()
+	q, err := compile(u)
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledUpdater[T]{u: u, q: q}, nil
+}
+
+func (c *CompiledUpdater[T]) Exec(ctx *middleware.Context, binds Bindings) *ExecResult {
+	ctx.Type = middleware.OpExec
+	if c.u.core.audit != nil && auditedModel[T]() {
+		// the before-images would be read with unbound parameters
+		return &ExecResult{
+			res: nil,
+			err: errs.ErrUnsupported,
+		}
+	}
+	// the executions may run concurrently, each hooks its own entity
+	u := *c.u
+	if u.val != nil {
+		val := *u.val
+		u.val = &val
+	}
+	if err := u.before(ctx); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
+	}
+	if err := c.q.bind(ctx, binds); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
+	}
+	res := u.core.run(ctx, u.opts, u.handleExec)
+	if res.Err != nil {
+		return &ExecResult{
+			res: nil,
+			err: res.Err,
+		}
+	}
+	return res.Res.(*ExecResult)
+}
@@ -0,0 +1,97 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCompiledSelector(t *testing.T) {
+	type TestModel struct {
+		ID   int64
+		Name string
+	}
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	calls := 0
+	db.Use(func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			calls++
+			return next(ctx)
+		}
+	})
+
+	q, err := NewSelector[TestModel](db).Where(C("ID").Eq(Param("id")), C("Name").Eq("wang")).Compile()
+	require.NoError(t, err)
+
+	query := "SELECT * FROM `test_model` WHERE (`id` = ?) AND (`name` = ?);"
+	mock.ExpectQuery(query).WithArgs(1, "wang").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "wang"))
+	mock.ExpectQuery(query).WithArgs(2, "wang").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "wang"))
+
+	res, err := q.Get(&middleware.Context{Ctx: context.Background()}, Bindings{"id": 1})
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{ID: 1, Name: "wang"}, res)
+	multi, err := q.GetMulti(&middleware.Context{Ctx: context.Background()}, Bindings{"id": 2})
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{{ID: 2, Name: "wang"}}, multi)
+	assert.Equal(t, 2, calls)
+
+	_, err = q.Get(&middleware.Context{Ctx: context.Background()}, Bindings{})
+	assert.Equal(t, errs.ErrUnboundParam, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type compiledHookModel struct {
+	ID   int64
+	Name string
+}
+
+func (c *compiledHookModel) BeforeUpdate(ctx context.Context, sess Session) error {
+	c.Name = "changed"
+	return nil
+}
+
+func TestCompiledUpdater(t *testing.T) {
+	type TestModel struct {
+		ID   int64
+		Name string
+	}
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	q, err := NewUpdater[TestModel](db).Set(Assign("Name", Param("name"))).
+		Where(C("ID").Eq(Param("id"))).Compile()
+	require.NoError(t, err)
+
+	query := "UPDATE `test_model` SET `name` = ? WHERE `id` = ?;"
+	mock.ExpectExec(query).WithArgs("wang", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs("li", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, binds := range []Bindings{{"name": "wang", "id": 1}, {"name": "li", "id": 2}} {
+		res := q.Exec(&middleware.Context{Ctx: context.Background()}, binds)
+		require.NoError(t, res.Err())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// the hooks run on a copy of the entity and cannot change the statement
+	entity := &compiledHookModel{Name: "wang"}
+	hq, err := NewUpdater[compiledHookModel](db).FromStruct(entity).Where(C("ID").Eq(Param("id"))).Compile()
+	require.NoError(t, err)
+	mock.ExpectExec("UPDATE `compiled_hook_model` SET `name` = ? WHERE `id` = ?;").
+		WithArgs("wang", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, hq.Exec(&middleware.Context{Ctx: context.Background()}, Bindings{"id": 1}).Err())
+	assert.Equal(t, &compiledHookModel{Name: "wang"}, entity)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = NewUpdater[TestModel](db).Where(C("ID").Eq(Param("id"))).Compile()
+	assert.Equal(t, errs.ErrUpdateNoColumns, err)
+	_, err = NewSelector[shardOrder](db).Compile()
+	assert.Equal(t, errs.ErrUnsupported, err)
+}
@@ -9,4 +9,10 @@ type core struct {
 	registry *model.Registry
 	dialect  Dialect
 	mdls     []middleware.Middleware
+	// chain is mdls composed once by DB.Use, ending with middleware.Dispatch,
+	// see core.run
+	chain    middleware.Handler
+	accessor AccessorFactory
+	// audit records the changes of the Audited models, see WithAudit
+	audit AuditSink
 }
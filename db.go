@@ -3,13 +3,21 @@ package go_orm
 import (
 	"context"
 	"database/sql"
+	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/middleware"
 	"github.com/kisara71/go-orm/model"
+	"time"
 )
 
 type DB struct {
 	core
-	db *sql.DB
+	db       *sql.DB
+	replicas []*sql.DB
+	policy   ReplicaPolicy
+	shards   []*sql.DB
+	stmts    *stmtCache
+	// eval runs the builders instead of db, see OpenEvaluator
+	eval Evaluator
 }
 
 func (d *DB) getCore() core {
@@ -17,14 +25,102 @@ func (d *DB) getCore() core {
 }
 
 func (d *DB) queryContext(ctx context.Context, s string, a ...any) (*sql.Rows, error) {
-	return d.db.QueryContext(ctx, s, a...)
+	if d.eval != nil {
+		return nil, errs.ErrUnsupported
+	}
+	if len(d.replicas) == 0 || isPrimaryForced(ctx) {
+		return d.query(ctx, d.db, s, a...)
+	}
+	idx := d.policy.Pick(ctx, len(d.replicas))
+	start := time.Now()
+	rows, err := d.query(ctx, d.replicas[idx], s, a...)
+	d.policy.Done(idx, time.Since(start), err)
+	return rows, err
+}
+
+func (d *DB) query(ctx context.Context, db *sql.DB, s string, a ...any) (*sql.Rows, error) {
+	if d.stmts == nil {
+		return db.QueryContext(ctx, s, a...)
+	}
+	stmt, release, err := d.stmts.prepare(ctx, db, s)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.QueryContext(ctx, a...)
 }
 
 func (d *DB) execContext(ctx context.Context, s string, a ...any) (sql.Result, error) {
-	return d.db.ExecContext(ctx, s, a...)
+	if d.eval != nil {
+		return nil, errs.ErrUnsupported
+	}
+	if d.stmts == nil {
+		return d.db.ExecContext(ctx, s, a...)
+	}
+	stmt, release, err := d.stmts.prepare(ctx, d.db, s)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.ExecContext(ctx, a...)
+}
+
+// StmtCacheStats reports the prepared statement cache usage, all zero when
+// WithStmtCache is not set.
+func (d *DB) StmtCacheStats() StmtCacheStats {
+	if d.stmts == nil {
+		return StmtCacheStats{}
+	}
+	return d.stmts.snapshot()
+}
+
+// Stats returns the connection pool statistics of the primary database.
+func (d *DB) Stats() sql.DBStats {
+	if d.db == nil {
+		return sql.DBStats{}
+	}
+	return d.db.Stats()
+}
+
+// Close closes the cached prepared statements and the primary database.
+// Replicas and shards are left to their owner.
+func (d *DB) Close() error {
+	if d.db == nil {
+		return nil
+	}
+	if d.stmts != nil {
+		if err := d.stmts.close(); err != nil {
+			return err
+		}
+	}
+	return d.db.Close()
+}
+
+func (d *DB) shard(idx int) (session, error) {
+	if len(d.shards) == 0 && idx == 0 {
+		return d, nil
+	}
+	if idx < 0 || idx >= len(d.shards) {
+		return nil, errs.ErrNoShard
+	}
+	return &DB{core: d.core, db: d.shards[idx], stmts: d.stmts}, nil
 }
 
 func (d *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Transaction, error) {
+	if d.eval != nil {
+		ev, ok := d.eval.(TxEvaluator)
+		if !ok {
+			return nil, errs.ErrUnsupported
+		}
+		tx, err := ev.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Transaction{
+			db:   d,
+			eval: tx,
+		}, nil
+	}
 	tx, err := d.db.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
@@ -35,21 +131,20 @@ func (d *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Transaction, er
 	}, nil
 }
 func (d *DB) DoTx(ctx context.Context, fn func(ctx context.Context, tx *Transaction) error) (err error) {
-	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{
+	tranz, err := d.BeginTx(ctx, &sql.TxOptions{
 		Isolation: 0,
 		ReadOnly:  false,
 	})
 	if err != nil {
 		return err
 	}
-	tranz := &Transaction{
-		db: d,
-		tx: tx,
-	}
 	panicked := true
 	defer func() {
 		if panicked || err != nil {
-			err = tranz.RollBack()
+			// keep the error of fn, the rollback one only matters without it
+			if rbErr := tranz.RollBack(); err == nil {
+				err = rbErr
+			}
 		} else {
 			err = tranz.Commit()
 		}
@@ -61,6 +156,7 @@ func (d *DB) DoTx(ctx context.Context, fn func(ctx context.Context, tx *Transact
 }
 func (d *DB) Use(middlewares ...middleware.Middleware) {
 	d.mdls = append(d.mdls, middlewares...)
+	d.chain = chain(d.mdls, middleware.Dispatch)
 }
 
 type DBOptions func(db *DB)
@@ -75,6 +171,7 @@ func Open(driver string, dsn string, options ...DBOptions) (*DB, error) {
 		core: core{
 			registry: &model.Registry{},
 			dialect:  StandardSQL,
+			accessor: NewUnsafeAccessor,
 		},
 	}
 	for _, opt := range options {
@@ -88,6 +185,7 @@ func OpenDB(sqldb *sql.DB, options ...DBOptions) *DB {
 		core: core{
 			registry: &model.Registry{},
 			dialect:  StandardSQL,
+			accessor: NewUnsafeAccessor,
 		},
 	}
 	for _, opt := range options {
@@ -101,3 +199,13 @@ func WithDialect(dialect Dialect) DBOptions {
 		db.dialect = dialect
 	}
 }
+
+// WithAccessorFactory selects how builders read and write model fields,
+// e.g. NewReflectAccessor where package unsafe is not acceptable, which
+// fails on unexported fields. Models implementing ModelAccessor bypass the
+// factory.
+func WithAccessorFactory(factory AccessorFactory) DBOptions {
+	return func(db *DB) {
+		db.accessor = factory
+	}
+}
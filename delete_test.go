@@ -2,6 +2,7 @@ package go_orm
 
 import (
 	"context"
+	"database/sql"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/middleware"
@@ -68,6 +69,34 @@ func TestDeletor_Build(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			// the last call replaces the predicates
+			name:    "delete with where twice",
+			builder: NewDeletor[TestModel](db).Where(C("Age").Eq(111)).Where(C("Name").Eq("hha")),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `name` = ?",
+				Args: []any{"hha"},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "delete with and where",
+			builder: NewDeletor[TestModel](db).Where(C("Age").Eq(111)).AndWhere(C("Name").Eq("hha")),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE (`age` = ?) AND (`name` = ?)",
+				Args: []any{111, "hha"},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "delete with table override and where",
+			builder: NewDeletor[TestModel](db).From("`table_test`").Where(C("Age").Eq(111)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `table_test` WHERE `age` = ?",
+				Args: []any{111},
+			},
+			wantErr: nil,
+		},
 		{
 			name: "delete with where not",
 			builder: func() *Deletor[TestModel] {
@@ -95,16 +124,58 @@ func TestDeletor_Build(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := &middleware.Context{Ctx: context.Background()}
-			err := tc.builder.Build(ctx)
+			q, err := tc.builder.Build()
 			assert.Equal(t, tc.wantErr, err)
 			if err != nil {
 				return
 			}
-			assert.Equal(t, tc.wantQuery, &Query{
-				SQL:  ctx.Statement,
-				Args: ctx.Args,
-			})
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestDeletor_Exec(t *testing.T) {
+	type TestModel struct {
+		Id   int64
+		Name string
+	}
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	testCases := []struct {
+		name         string
+		expect       func(mk sqlmock.Sqlmock)
+		wantAffected int64
+		wantErr      error
+	}{
+		{
+			name: "deleted",
+			expect: func(mk sqlmock.Sqlmock) {
+				mk.ExpectExec("DELETE FROM `test_model` WHERE `id` > \\?").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			wantAffected: 2,
+		},
+		{
+			name: "exec error",
+			expect: func(mk sqlmock.Sqlmock) {
+				mk.ExpectExec("DELETE FROM `test_model` WHERE `id` > \\?").WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.expect(mock)
+			res := NewDeletor[TestModel](db).Where(C("Id").GT(1)).Exec(&middleware.Context{Ctx: context.Background()})
+			assert.Equal(t, tc.wantErr, res.Err())
+			if tc.wantErr != nil {
+				return
+			}
+			affected, err := res.RowsAffected()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantAffected, affected)
 		})
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -1,21 +1,29 @@
 package go_orm
 
 import (
+	"context"
 	"database/sql"
+	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/middleware"
+	"slices"
 )
 
 var _ Builder = &Deletor[any]{}
 
 type Deletor[T any] struct {
-	tableName string
-	where     []Predicate
-	builder   *builder
-	sess      session
-	core      core
+	tableName  string
+	where      []Predicate
+	builder    *builder
+	sess       Session
+	core       core
+	shardTable string
+	opts       queryOptions
+	skip       scopeSkip
+	// fullTable lets the statement run without WHERE, see AllowFullTable
+	fullTable bool
 }
 
-func NewDeletor[T any](sess session) *Deletor[T] {
+func NewDeletor[T any](sess Session) *Deletor[T] {
 	c := sess.getCore()
 	return &Deletor[T]{
 		core:  c,
@@ -24,51 +32,160 @@ func NewDeletor[T any](sess session) *Deletor[T] {
 	}
 }
 
-func (d *Deletor[T]) Build(ctx *middleware.Context) error {
+// Clone returns a copy of d which is modified and run independently of d.
+func (d *Deletor[T]) Clone() *Deletor[T] {
+	c := *d
+	c.where = slices.Clone(d.where)
+	c.opts = d.opts.clone()
+	c.skip = d.skip.clone()
+	c.builder, c.shardTable = nil, ""
+	return &c
+}
+
+// ToSQL returns the statement with its args interpolated, for debugging.
+// It is built as for context.Background(), see Render.
+func (d *Deletor[T]) ToSQL() (string, error) {
+	return toSQL(d, d.core.dialect)
+}
+
+// Build builds the statement as for context.Background(), see BuildContext.
+func (d *Deletor[T]) Build() (*Query, error) {
+	return d.BuildContext(context.Background())
+}
+
+// BuildContext builds the statement for ctx without running it, d is left
+// untouched.
+func (d *Deletor[T]) BuildContext(ctx context.Context) (*Query, error) {
+	c := *d
+	return buildQuery(ctx, &c)
+}
+
+func (d *Deletor[T]) build(ctx *middleware.Context) error {
 	m, err := d.core.registry.Get(new(T))
 	if err != nil {
 		return err
 	}
 	d.builder = NewBuilder(m, d.core.dialect)
+	d.builder.table = d.shardTable
+	d.builder.buildComment(d.opts.comment)
 	d.builder.buildString("DELETE FROM ")
 	if d.tableName == "" {
-		d.builder.quote(d.builder.m.TableName)
+		d.builder.buildTable(d.builder.m)
 	} else {
+		d.builder.addTable(d.tableName)
 		d.builder.buildString(d.tableName)
 	}
-	if len(d.where) > 0 {
+	where := defaultScoped(ctx.Ctx, d.core, new(T), d.skip, d.where)
+	where, err = scoped(ctx.Ctx, d.builder.m, false, where)
+	if err != nil {
+		return err
+	}
+	d.builder.stmt = Statement{Kind: middleware.KindDelete, Model: m, where: where}
+	if len(where) > 0 {
 		d.builder.buildString(" WHERE ")
-		p := d.where[0]
-		for i := 1; i < len(d.where); i++ {
-			p = p.And(d.where[i])
+		p := where[0]
+		for i := 1; i < len(where); i++ {
+			p = p.And(where[i])
 		}
 		err = d.builder.buildExpression(p, ClauseWhere)
 		if err != nil {
 			return err
 		}
 	}
+	ctx.Model = d.builder.m
+	ctx.Kind = middleware.KindDelete
+	ctx.Tables = d.builder.tables
+	ctx.HasWhere = len(d.where) > 0
+	ctx.FullTable = d.fullTable
 	ctx.SetArgs(d.builder.getArgs())
+	ctx.ArgColumns = d.builder.getArgColumns()
 	ctx.SetStatement(d.builder.getSQL())
 	return nil
 }
-func (d *Deletor[T]) From(tableName string) {
+func (d *Deletor[T]) From(tableName string) *Deletor[T] {
 	d.tableName = tableName
+	return d
+}
+
+// Scopes applies scopes to the statement. A scope filtering the rows calls
+// AndWhere, Where would replace the caller's predicates.
+func (d *Deletor[T]) Scopes(scopes ...func(*Deletor[T]) *Deletor[T]) *Deletor[T] {
+	for _, scope := range scopes {
+		d = scope(d)
+	}
+	return d
+}
+
+// Unscoped disables the default scopes named names, or all of them when
+// none is given.
+func (d *Deletor[T]) Unscoped(names ...string) *Deletor[T] {
+	d.skip.add(names)
+	return d
+}
+
+// AllowFullTable marks the statement as meant to delete every row, so that
+// the guard middleware lets it run without WHERE.
+func (d *Deletor[T]) AllowFullTable() *Deletor[T] {
+	d.fullTable = true
+	return d
+}
+
+func (d *Deletor[T]) With(opts ...QueryOption) *Deletor[T] {
+	d.opts.apply(opts)
+	return d
 }
-func (d *Deletor[T]) Where(predicate ...Predicate) {
+
+// Where sets the predicates of the WHERE clause, replacing those of the
+// previous calls, see AndWhere.
+func (d *Deletor[T]) Where(predicate ...Predicate) *Deletor[T] {
 	d.where = predicate
+	return d
+}
+
+// AndWhere adds predicates to the WHERE clause, combined with AND with those
+// set before.
+func (d *Deletor[T]) AndWhere(predicate ...Predicate) *Deletor[T] {
+	// the predicates given to Where may be the caller's slice
+	d.where = append(slices.Clip(d.where), predicate...)
+	return d
+}
+
+func (d *Deletor[T]) exec(ctx *middleware.Context, sess session) (sql.Result, error) {
+	if ev, ok := evaluatorOf(sess); ok {
+		if d.tableName != "" {
+			return nil, errs.ErrUnsupported
+		}
+		return ev.Exec(ctx.Ctx, &d.builder.stmt)
+	}
+	rule, ok := shardingRuleOf[T]()
+	if !ok {
+		return sess.execContext(ctx.Ctx, ctx.Statement, ctx.Args...)
+	}
+	dsts, err := shardDsts(rule, d.where)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		d.shardTable = ""
+	}()
+	return execShards(ctx, sess, dsts, func(ctx *middleware.Context, _ int, dst ShardDst) error {
+		d.shardTable = dst.Table
+		return d.build(ctx)
+	})
 }
 
 var _ middleware.Handler = (&Deletor[any]{}).handleExec
 
+// handleExec expects ctx to hold the statement built by Exec.
 func (d *Deletor[T]) handleExec(ctx *middleware.Context) *middleware.Result {
-	err := d.Build(ctx)
-	if err != nil {
+	if d.tableName != "" && d.core.audit != nil && auditedModel[T]() {
+		// the rows of another table cannot be read back as T
 		return &middleware.Result{
 			Res: nil,
-			Err: err,
+			Err: errs.ErrUnsupported,
 		}
 	}
-	res, err := d.sess.execContext(ctx.Ctx, ctx.Statement, ctx.Args...)
+	res, err := auditExec[T](ctx, d.core, d.sess, middleware.KindDelete, d.where, d.skip, nil, d.exec)
 	if err != nil {
 		return &middleware.Result{
 			Res: nil,
@@ -84,20 +201,28 @@ func (d *Deletor[T]) handleExec(ctx *middleware.Context) *middleware.Result {
 	}
 }
 func (d *Deletor[T]) Exec(ctx *middleware.Context) *ExecResult {
+	// run on a copy, d may be shared by several goroutines
+	c := *d
+	d = &c
 	ctx.Type = middleware.OpExec
-	root := d.handleExec
-	for i := len(d.core.mdls) - 1; i >= 0; i-- {
-		root = d.core.mdls[i](root)
+	if err := beforeDelete(ctx.Ctx, d.sess, new(T)); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
 	}
-	res := root(ctx)
+	if err := d.build(ctx); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
+	}
+	res := d.core.run(ctx, d.opts, d.handleExec)
 	if res.Err != nil {
 		return &ExecResult{
 			res: nil,
 			err: res.Err,
 		}
 	}
-	return &ExecResult{
-		res: res.Res.(sql.Result),
-		err: nil,
-	}
+	return res.Res.(*ExecResult)
 }
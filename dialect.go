@@ -1,10 +1,25 @@
 package go_orm
 
-import "github.com/kisara71/go-orm/errs"
+import (
+	"encoding/hex"
+	"github.com/kisara71/go-orm/errs"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Dialect interface {
 	Quoter() byte
 	BuildUpsert(builder *builder, opk *OnConflict) error
+	// Literal returns val, a nil, int64, float64, bool, string, []byte or
+	// time.Time, as a literal of the dialect. See Render.
+	Literal(val any) (string, error)
+	// numbered tells whether the placeholders are written $1, $2... rather
+	// than ?, see placeholders
+	numbered() bool
+	// backslashEscapes tells whether a backslash escapes the next character
+	// of a string literal, as \' in MySQL
+	backslashEscapes() bool
 }
 
 var (
@@ -21,10 +36,44 @@ func (s *standardSQL) Quoter() byte {
 	return '"'
 }
 
+func (s *standardSQL) numbered() bool {
+	return false
+}
+
+func (s *standardSQL) backslashEscapes() bool {
+	return false
+}
+
 func (s *standardSQL) BuildUpsert(builder *builder, opk *OnConflict) error {
 	return errs.ErrUnsupported
 }
 
+func (s *standardSQL) Literal(val any) (string, error) {
+	switch v := val.(type) {
+	case nil:
+		return "NULL", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case []byte:
+		if v == nil {
+			return "NULL", nil
+		}
+		return "X'" + hex.EncodeToString(v) + "'", nil
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999999-07:00") + "'", nil
+	}
+	return "", errs.ErrUnsupportedType
+}
+
 type mysqlDialect struct {
 	*standardSQL
 }
@@ -32,6 +81,27 @@ type mysqlDialect struct {
 func (m *mysqlDialect) Quoter() byte {
 	return '`'
 }
+
+func (m *mysqlDialect) backslashEscapes() bool {
+	return true
+}
+
+// mysqlEscaper escapes the characters escaped by mysql_real_escape_string.
+var mysqlEscaper = strings.NewReplacer(
+	"\\", "\\\\", "'", "\\'", "\"", "\\\"", "\x00", "\\0",
+	"\n", "\\n", "\r", "\\r", "\x1a", "\\Z",
+)
+
+func (m *mysqlDialect) Literal(val any) (string, error) {
+	switch v := val.(type) {
+	case string:
+		return "'" + mysqlEscaper.Replace(v) + "'", nil
+	case time.Time:
+		// the driver sends times in UTC unless configured otherwise
+		return "'" + v.UTC().Format("2006-01-02 15:04:05.999999") + "'", nil
+	}
+	return m.standardSQL.Literal(val)
+}
 func (m *mysqlDialect) BuildUpsert(builder *builder, opk *OnConflict) error {
 	builder.buildString(" ON DUPLICATE KEY UPDATE ")
 	for idx, assign := range opk.assigns {
@@ -44,7 +114,9 @@ func (m *mysqlDialect) BuildUpsert(builder *builder, opk *OnConflict) error {
 				return err
 			}
 			builder.buildString(" = ?")
+			unbind := builder.bindColumn(as.column.name)
 			builder.addArgs(as.val)
+			unbind()
 		case Column:
 			err := builder.buildColumn(as)
 			if err != nil {
@@ -84,7 +156,9 @@ func (s *sqliteDialect) BuildUpsert(builder *builder, opk *OnConflict) error {
 				return err
 			}
 			builder.buildString(" = ?")
+			unbind := builder.bindColumn(as.column.name)
 			builder.addArgs(as.val)
+			unbind()
 		case Column:
 			err := builder.buildColumn(as)
 			if err != nil {
@@ -97,10 +171,37 @@ func (s *sqliteDialect) BuildUpsert(builder *builder, opk *OnConflict) error {
 	return nil
 }
 
+func (s *sqliteDialect) Literal(val any) (string, error) {
+	// booleans are stored as integers
+	if b, ok := val.(bool); ok {
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	}
+	return s.standardSQL.Literal(val)
+}
+
 type postgreDialect struct {
 	*standardSQL
 }
 
+func (p *postgreDialect) numbered() bool {
+	return true
+}
+
+func (p *postgreDialect) Literal(val any) (string, error) {
+	switch v := val.(type) {
+	case []byte:
+		if v != nil {
+			return "'\\x" + hex.EncodeToString(v) + "'::bytea", nil
+		}
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999-07:00") + "'", nil
+	}
+	return p.standardSQL.Literal(val)
+}
+
 func (p *postgreDialect) BuildUpsert(builder *builder, opk *OnConflict) error {
 	builder.buildString(" ON CONFLICT(")
 	for i, col := range opk.conflictColumns {
@@ -123,7 +224,9 @@ func (p *postgreDialect) BuildUpsert(builder *builder, opk *OnConflict) error {
 				return err
 			}
 			builder.buildString(" = ?")
+			unbind := builder.bindColumn(as.column.name)
 			builder.addArgs(as.val)
+			unbind()
 		case Column:
 			err := builder.buildColumn(as)
 			if err != nil {
@@ -16,4 +16,21 @@ var (
 	ErrUnsupported      = errors.New("unsupported operation for this dialect")
 	ErrUnsupportedType  = errors.New("unsupported param type")
 	ErrUpdateNoColumns  = errors.New("do update with no columns")
+	ErrNoEntity         = errors.New("accessor has no entity, call Access first")
+	ErrNoShard          = errors.New("no shard matches the shard key")
+	ErrInvalidShardKey  = errors.New("shard key must be an integer for this algorithm")
+	ErrUnboundParam     = errors.New("compiled query executed without a binding for one of its parameters")
+	ErrShardInTx        = errors.New("sharded models spanning several databases cannot be used in a transaction")
+	ErrFullTable        = errors.New("update or delete without WHERE, call AllowFullTable to run it")
+	ErrNoLimit          = errors.New("select without LIMIT on a large table")
+	ErrDenied           = errors.New("statement denied by the guard")
+	ErrNoTenant         = errors.New("context has no tenant, use WithTenant or WithoutTenant")
+	ErrTenantMismatch   = errors.New("entity belongs to another tenant than the context")
+	ErrTenantAssign     = errors.New("statement scoped to a tenant assigns the tenant column")
+	ErrGoldenMismatch   = errors.New("statement differs from the one recorded in the golden file")
+	ErrDuplicateKey     = errors.New("duplicate primary key")
+	ErrNoPrimaryKey     = errors.New("model has no primary key, tag one with orm:\"pk\"")
+	ErrNoHandler        = errors.New("middleware context has no handler to dispatch to")
+	ErrOrderNotSelected = errors.New("rows merged from several shards are ordered by columns which are not selected")
+	ErrUnexportedField  = errors.New("unexported field, only reachable through package unsafe")
 )
@@ -0,0 +1,339 @@
+package go_orm
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/model"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Evaluator runs the statements of the builders itself instead of sending
+// their SQL to a database, e.g. on rows held in memory. See OpenEvaluator.
+type Evaluator interface {
+	// Query returns the rows matching st, a *T of the model of st each. The
+	// caller may modify them.
+	Query(ctx context.Context, st *Statement) ([]any, error)
+	// Exec runs an INSERT, UPDATE or DELETE.
+	Exec(ctx context.Context, st *Statement) (sql.Result, error)
+}
+
+// TxEvaluator is implemented by the Evaluators supporting transactions,
+// BeginTx fails with errs.ErrUnsupported on the others.
+type TxEvaluator interface {
+	Evaluator
+	Begin(ctx context.Context) (EvaluatorTx, error)
+}
+
+// EvaluatorTx is a transaction of a TxEvaluator. Commit and Rollback return
+// sql.ErrTxDone once the transaction ended.
+type EvaluatorTx interface {
+	Evaluator
+	Commit() error
+	Rollback() error
+}
+
+// OpenEvaluator returns a DB running the builders on ev. Hooks, middlewares,
+// scopes and tenants apply as usual; joins, GROUP BY, HAVING, aggregates,
+// raw expressions, upserts and compiled statements are not supported.
+func OpenEvaluator(ev Evaluator, options ...DBOptions) *DB {
+	db := &DB{
+		eval: ev,
+		core: core{
+			registry: &model.Registry{},
+			dialect:  StandardSQL,
+			accessor: NewUnsafeAccessor,
+		},
+	}
+	for _, opt := range options {
+		opt(db)
+	}
+	return db
+}
+
+// evaluatorOf returns the Evaluator sess runs on, if any.
+func evaluatorOf(sess session) (Evaluator, bool) {
+	switch s := sess.(type) {
+	case *DB:
+		return s.eval, s.eval != nil
+	case *Transaction:
+		return s.eval, s.eval != nil
+	}
+	return nil, false
+}
+
+// Statement is a statement of a builder handed to an Evaluator.
+type Statement struct {
+	Kind  middleware.OpKind
+	Model *model.Model
+	// Values holds the *T inserted by an INSERT
+	Values []any
+	// Columns are the fields set by an INSERT
+	Columns []*model.FieldInfo
+	Limit   int64
+	Offset  int64
+
+	// where holds the WHERE predicates, scopes included
+	where []Predicate
+	sets  []set
+	order []OrderBy
+}
+
+// set is the assignment of val to a field by an UPDATE.
+type set struct {
+	field *model.FieldInfo
+	val   any
+}
+
+// Match reports whether the WHERE clause holds for entity, a *T. As in SQL,
+// a comparison with NULL never holds.
+func (st *Statement) Match(entity any) (bool, error) {
+	val := reflect.ValueOf(entity).Elem()
+	for _, p := range st.where {
+		res, err := st.eval(p, val)
+		if err != nil || res != truthy {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Apply sets the fields assigned by an UPDATE on entity, a *T.
+func (st *Statement) Apply(entity any) error {
+	val := reflect.ValueOf(entity).Elem()
+	for _, s := range st.sets {
+		field := s.field.Settable(val)
+		if s.val == nil {
+			field.SetZero()
+			continue
+		}
+		v := reflect.ValueOf(s.val)
+		switch {
+		case v.Type().AssignableTo(field.Type()):
+			field.Set(v)
+		case v.Type().ConvertibleTo(field.Type()):
+			field.Set(v.Convert(field.Type()))
+		case field.Addr().Type().Implements(scannerType):
+			if err := field.Addr().Interface().(sql.Scanner).Scan(s.val); err != nil {
+				return err
+			}
+		default:
+			return errs.ErrUnsupportedType
+		}
+	}
+	return nil
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// Sort orders rows, *T each, by the ORDER BY clause. NULL comes first.
+func (st *Statement) Sort(rows []any) error {
+	fields := make([]*model.FieldInfo, 0, len(st.order))
+	for _, ob := range st.order {
+		fd, ok := st.Model.GoMap[ob.col.name]
+		if !ok {
+			return errs.ErrUnknownField
+		}
+		fields = append(fields, fd)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := reflect.ValueOf(rows[i]).Elem(), reflect.ValueOf(rows[j]).Elem()
+		for idx, fd := range fields {
+			x, y := normalize(fd.Value(a).Interface()), normalize(fd.Value(b).Interface())
+			c, ok := compare(x, y)
+			if !ok {
+				// NULL first, incomparable values keep their order
+				c = nullOrder(x, y)
+			}
+			if c == 0 {
+				continue
+			}
+			if st.order[idx].order == "DESC" {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+func nullOrder(x, y any) int {
+	switch {
+	case x == nil && y != nil:
+		return -1
+	case x != nil && y == nil:
+		return 1
+	}
+	return 0
+}
+
+// truth is the three-valued logic of SQL, ordered so that AND is min and
+// OR is max.
+type truth int8
+
+const (
+	falsy truth = iota
+	unknown
+	truthy
+)
+
+func (st *Statement) eval(p Predicate, val reflect.Value) (truth, error) {
+	switch p.op {
+	case opAnd, opOr:
+		l, err := st.eval(p.left.(Predicate), val)
+		if err != nil {
+			return unknown, err
+		}
+		r, err := st.eval(p.right.(Predicate), val)
+		if err != nil {
+			return unknown, err
+		}
+		if p.op == opAnd {
+			return min(l, r), nil
+		}
+		return max(l, r), nil
+	case opNot:
+		r, err := st.eval(p.right.(Predicate), val)
+		switch r {
+		case truthy:
+			return falsy, err
+		case falsy:
+			return truthy, err
+		}
+		return unknown, err
+	case opEq, opLT, opGT:
+		l, err := st.operand(p.left, val)
+		if err != nil {
+			return unknown, err
+		}
+		r, err := st.operand(p.right, val)
+		if err != nil {
+			return unknown, err
+		}
+		c, ok := compare(l, r)
+		if !ok {
+			return unknown, nil
+		}
+		if (p.op == opEq && c == 0) || (p.op == opLT && c < 0) || (p.op == opGT && c > 0) {
+			return truthy, nil
+		}
+		return falsy, nil
+	}
+	if t, ok := p.left.(tenantScope); ok {
+		return st.eval(Predicate{left: C(t.m.Tenant.GoName), op: opEq, right: Arg{val: t.val}}, val)
+	}
+	return unknown, errs.ErrUnsupported
+}
+
+// operand returns the normalized value of a side of a comparison.
+func (st *Statement) operand(e Expression, val reflect.Value) (any, error) {
+	if a, ok := e.(Arg); ok {
+		switch v := a.val.(type) {
+		case Parameter:
+			return nil, errs.ErrUnboundParam
+		case Expression:
+			e = v
+		default:
+			return normalize(v), nil
+		}
+	}
+	col, ok := columnOf(e)
+	if !ok {
+		return nil, errs.ErrUnsupported
+	}
+	fd, ok := st.Model.GoMap[col.name]
+	if !ok {
+		return nil, errs.ErrUnknownField
+	}
+	return normalize(fd.Value(val).Interface()), nil
+}
+
+// normalize turns v into nil, int64, float64, string, bool, time.Time or
+// []byte when it can, unwrapping pointers and driver.Valuer.
+func normalize(v any) any {
+	if valuer, ok := v.(driver.Valuer); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+		dv, err := valuer.Value()
+		if err != nil {
+			return v
+		}
+		v = dv
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u)
+		}
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes()
+		}
+	}
+	return v
+}
+
+// compare compares normalized values, ok is false when either is NULL or
+// they are not comparable.
+func compare(a, b any) (c int, ok bool) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, y), true
+		case float64:
+			return cmpOrdered(float64(x), y), true
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, float64(y)), true
+		case float64:
+			return cmpOrdered(x, y), true
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return cmpOrdered(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			return cmpOrdered(boolToInt(x), boolToInt(y)), true
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y), true
+		}
+	}
+	return 0, false
+}
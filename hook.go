@@ -0,0 +1,77 @@
+package go_orm
+
+import "context"
+
+// Models may implement any of the hook interfaces below. Hooks receive the
+// session the statement runs on, so queries they issue join the same
+// transaction. An error returned by a Before hook aborts the statement; an
+// error returned by an After hook is reported as the statement's error.
+
+type BeforeInsertHook interface {
+	BeforeInsert(ctx context.Context, sess Session) error
+}
+
+type AfterInsertHook interface {
+	AfterInsert(ctx context.Context, sess Session) error
+}
+
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context, sess Session) error
+}
+
+type AfterUpdateHook interface {
+	AfterUpdate(ctx context.Context, sess Session) error
+}
+
+// BeforeDeleteHook is invoked on a zero value of the model, as a Deletor
+// carries no entity: the hook cannot tell which rows are deleted, it suits
+// checks on ctx such as permissions.
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context, sess Session) error
+}
+
+type AfterFindHook interface {
+	AfterFind(ctx context.Context, sess Session) error
+}
+
+func beforeInsert(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(BeforeInsertHook); ok {
+		return h.BeforeInsert(ctx, sess)
+	}
+	return nil
+}
+
+func afterInsert(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(AfterInsertHook); ok {
+		return h.AfterInsert(ctx, sess)
+	}
+	return nil
+}
+
+func beforeUpdate(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(BeforeUpdateHook); ok {
+		return h.BeforeUpdate(ctx, sess)
+	}
+	return nil
+}
+
+func afterUpdate(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(AfterUpdateHook); ok {
+		return h.AfterUpdate(ctx, sess)
+	}
+	return nil
+}
+
+func beforeDelete(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(BeforeDeleteHook); ok {
+		return h.BeforeDelete(ctx, sess)
+	}
+	return nil
+}
+
+func afterFind(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(AfterFindHook); ok {
+		return h.AfterFind(ctx, sess)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package go_orm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var errHookRejected = errors.New("rejected by hook")
+
+type hookModel struct {
+	ID   int64
+	Name string
+}
+
+type hookCallsKey struct{}
+
+// recordHook appends the hook name to the recorder carried by ctx.
+func recordHook(ctx context.Context, name string) {
+	if calls, ok := ctx.Value(hookCallsKey{}).(*[]string); ok {
+		*calls = append(*calls, name)
+	}
+}
+
+func (h *hookModel) BeforeInsert(ctx context.Context, sess Session) error {
+	if h.Name == "" {
+		return errHookRejected
+	}
+	h.Name = "before_" + h.Name
+	recordHook(ctx, "BeforeInsert")
+	return nil
+}
+
+func (h *hookModel) AfterInsert(ctx context.Context, sess Session) error {
+	recordHook(ctx, "AfterInsert")
+	return nil
+}
+
+func (h *hookModel) BeforeUpdate(ctx context.Context, sess Session) error {
+	recordHook(ctx, "BeforeUpdate")
+	return nil
+}
+
+func (h *hookModel) AfterUpdate(ctx context.Context, sess Session) error {
+	recordHook(ctx, "AfterUpdate")
+	return nil
+}
+
+func (h *hookModel) AfterFind(ctx context.Context, sess Session) error {
+	h.Name = "found_" + h.Name
+	return nil
+}
+
+// BeforeDelete writes an audit row on the session the delete runs on.
+func (h *hookModel) BeforeDelete(ctx context.Context, sess Session) error {
+	return NewInsertor[hookAudit](sess).Values(&hookAudit{Action: "delete"}).
+		Exec(&middleware.Context{Ctx: ctx}).Err()
+}
+
+type hookAudit struct {
+	Action string
+}
+
+func TestHooks_Insert(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	var calls []string
+	ctx := context.WithValue(context.Background(), hookCallsKey{}, &calls)
+	mock.ExpectExec("INSERT INTO `hook_model`").
+		WithArgs(int64(1), "before_wang").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	res := NewInsertor[hookModel](db).Values(&hookModel{ID: 1, Name: "wang"}).
+		Exec(&middleware.Context{Ctx: ctx})
+	require.NoError(t, res.Err())
+	assert.Equal(t, []string{"BeforeInsert", "AfterInsert"}, calls)
+
+	res = NewInsertor[hookModel](db).Values(&hookModel{ID: 2}).
+		Exec(&middleware.Context{Ctx: context.Background()})
+	assert.Equal(t, errHookRejected, res.Err())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_Update(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	var calls []string
+	ctx := context.WithValue(context.Background(), hookCallsKey{}, &calls)
+	mock.ExpectExec("UPDATE `hook_model` SET `name` = \\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	res := NewUpdater[hookModel](db).FromStruct(&hookModel{Name: "wang"}).Where(C("ID").Eq(1)).
+		Exec(&middleware.Context{Ctx: ctx})
+	require.NoError(t, res.Err())
+	assert.Equal(t, []string{"BeforeUpdate", "AfterUpdate"}, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_DeleteInTransaction(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `hook_audit`").WithArgs("delete").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM `hook_model`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err = db.DoTx(context.Background(), func(ctx context.Context, tx *Transaction) error {
+		d := NewDeletor[hookModel](tx)
+		d.Where(C("ID").Eq(1))
+		return d.Exec(&middleware.Context{Ctx: ctx}).Err()
+	})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_AfterFind(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "wang"))
+	res, err := NewSelector[hookModel](db).Get(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.Equal(t, "found_wang", res.Name)
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+		AddRow(1, "wang").AddRow(2, "li"))
+	multi, err := NewSelector[hookModel](db).GetMulti(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.Equal(t, []*hookModel{{ID: 1, Name: "found_wang"}, {ID: 2, Name: "found_li"}}, multi)
+}
@@ -1,9 +1,13 @@
 package go_orm
 
 import (
+	"context"
+	"database/sql"
 	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/middleware"
 	"github.com/kisara71/go-orm/model"
+	"reflect"
+	"slices"
 )
 
 var _ Builder = &Insertor[any]{}
@@ -13,11 +17,13 @@ type Insertor[T any] struct {
 	values     []*T
 	onConflict *OnConflict
 	core       core
-	sess       session
+	sess       Session
 	builder    *builder
+	shardTable string
+	opts       queryOptions
 }
 
-func NewInsertor[T any](sess session) *Insertor[T] {
+func NewInsertor[T any](sess Session) *Insertor[T] {
 	c := sess.getCore()
 	return &Insertor[T]{
 		sess:   sess,
@@ -26,6 +32,22 @@ func NewInsertor[T any](sess session) *Insertor[T] {
 	}
 }
 
+// Clone returns a copy of i which is modified and run independently of i.
+func (i *Insertor[T]) Clone() *Insertor[T] {
+	c := *i
+	c.columns = slices.Clone(i.columns)
+	c.values = slices.Clone(i.values)
+	if i.onConflict != nil {
+		c.onConflict = &OnConflict{
+			assigns:         slices.Clone(i.onConflict.assigns),
+			conflictColumns: slices.Clone(i.onConflict.conflictColumns),
+		}
+	}
+	c.opts = i.opts.clone()
+	c.builder, c.shardTable = nil, ""
+	return &c
+}
+
 type OnConflictBuilder[T any] struct {
 	i          *Insertor[T]
 	onConflict *OnConflict
@@ -37,7 +59,12 @@ type OnConflict struct {
 }
 
 func (o *OnConflictBuilder[T]) Update(assigns ...Assignable) *Insertor[T] {
-	o.onConflict.assigns = append(o.onConflict.assigns, assigns...)
+	for _, assign := range assigns {
+		if c, ok := assign.(columnar); ok {
+			assign = c.Column()
+		}
+		o.onConflict.assigns = append(o.onConflict.assigns, assign)
+	}
 	o.i.onConflict = o.onConflict
 	return o.i
 }
@@ -56,7 +83,25 @@ func (i *Insertor[T]) OnConflict() *OnConflictBuilder[T] {
 	}
 }
 
-func (i *Insertor[T]) Build(ctx *middleware.Context) error {
+// ToSQL returns the statement with its args interpolated, for debugging.
+// It is built as for context.Background(), see Render.
+func (i *Insertor[T]) ToSQL() (string, error) {
+	return toSQL(i, i.core.dialect)
+}
+
+// Build builds the statement as for context.Background(), see BuildContext.
+func (i *Insertor[T]) Build() (*Query, error) {
+	return i.BuildContext(context.Background())
+}
+
+// BuildContext builds the statement for ctx without running it, i is left
+// untouched.
+func (i *Insertor[T]) BuildContext(ctx context.Context) (*Query, error) {
+	c := *i
+	return buildQuery(ctx, &c)
+}
+
+func (i *Insertor[T]) build(ctx *middleware.Context) error {
 	if len(i.values) == 0 {
 		return errs.ErrInsertNoValues
 	}
@@ -64,10 +109,22 @@ func (i *Insertor[T]) Build(ctx *middleware.Context) error {
 	if err != nil {
 		return err
 	}
+	tenant, scopedTenant, err := tenantOf(ctx.Ctx, m)
+	if err != nil {
+		return err
+	}
+	vals := i.values
+	if scopedTenant {
+		if vals, err = i.withTenant(m, tenant); err != nil {
+			return err
+		}
+	}
 	i.builder = NewBuilder(m, i.core.dialect)
+	i.builder.table = i.shardTable
+	i.builder.buildComment(i.opts.comment)
 	i.builder.sb.WriteString("INSERT INTO ")
 
-	i.builder.quote(i.builder.m.TableName)
+	i.builder.buildTable(i.builder.m)
 	i.builder.buildByte(' ')
 	fields := i.builder.m.Fields
 	if len(i.columns) == 0 {
@@ -93,40 +150,115 @@ func (i *Insertor[T]) Build(ctx *middleware.Context) error {
 			}
 			i.builder.quote(fields[idx].ColName)
 		}
+		if scopedTenant && !slices.Contains(fields, m.Tenant) {
+			fields = append(fields, m.Tenant)
+			i.builder.buildString(", ")
+			i.builder.quote(m.Tenant.ColName)
+		}
 		i.builder.buildByte(')')
 	}
 
 	i.builder.buildString(" VALUES ")
-	accessor := NewUnsafeAccessor(i.builder.m)
-	for idx1, val := range i.values {
+	goNames := make([]string, 0, len(fields))
+	for _, fd := range fields {
+		goNames = append(goNames, fd.GoName)
+	}
+	accessor := i.core.accessor(i.builder.m)
+	for idx1, val := range vals {
 		if idx1 > 0 {
 			i.builder.buildString(", ")
 		}
-		//rval := reflect.ValueOf(val).Elem()
-		accessor.Access(val)
+		args, err := fieldValues(accessor, val, goNames)
+		if err != nil {
+			return err
+		}
 		i.builder.buildByte('(')
-		for idx2, fd := range fields {
+		for idx2, arg := range args {
 			if idx2 > 0 {
 				i.builder.buildString(", ")
 			}
 			i.builder.buildByte('?')
-			arg, err := accessor.Fetch(fd.GoName)
-			if err != nil {
-				return err
-			}
+			unbind := i.builder.bindColumn(goNames[idx2])
 			i.builder.addArgs(arg)
+			unbind()
 		}
 		i.builder.buildByte(')')
 	}
 
 	if i.onConflict != nil {
+		// the conflicting row may belong to another tenant
+		if scopedTenant && assignsTenant(m, i.onConflict.assigns) {
+			return errs.ErrTenantAssign
+		}
 		if err = i.core.dialect.BuildUpsert(i.builder, i.onConflict); err != nil {
 			return err
 		}
 	}
+	values := make([]any, 0, len(vals))
+	for _, val := range vals {
+		values = append(values, val)
+	}
+	i.builder.stmt = Statement{Kind: middleware.KindInsert, Model: m, Values: values, Columns: fields}
 	i.builder.buildByte(';')
+	ctx.Model = i.builder.m
+	ctx.Kind = middleware.KindInsert
+	if i.onConflict != nil {
+		ctx.Kind = middleware.KindUpsert
+	}
+	ctx.Tables = i.builder.tables
 	ctx.SetStatement(i.builder.getSQL())
 	ctx.SetArgs(i.builder.getArgs())
+	ctx.ArgColumns = i.builder.getArgColumns()
+	return nil
+}
+
+func (i *Insertor[T]) With(opts ...QueryOption) *Insertor[T] {
+	i.opts.apply(opts)
+	return i
+}
+
+// withTenant returns the values holding tenant, which must be zero or
+// already hold it. The values to fill are copied, the entities are filled by
+// Exec only.
+func (i *Insertor[T]) withTenant(m *model.Model, tenant any) ([]*T, error) {
+	tv := reflect.ValueOf(tenant)
+	if tv.Type() != m.Tenant.Type {
+		return nil, errs.ErrTenantMismatch
+	}
+	res := make([]*T, 0, len(i.values))
+	for _, val := range i.values {
+		if fv := m.Tenant.Value(reflect.ValueOf(val).Elem()); !fv.IsZero() {
+			if !fv.Equal(tv) {
+				return nil, errs.ErrTenantMismatch
+			}
+			res = append(res, val)
+			continue
+		}
+		c := *val
+		m.Tenant.Detach(reflect.ValueOf(&c).Elem())
+		m.Tenant.Settable(reflect.ValueOf(&c).Elem()).Set(tv)
+		res = append(res, &c)
+	}
+	return res, nil
+}
+
+// fillTenant sets the tenant field of the values left zero, once the
+// statement is built and the values checked.
+func (i *Insertor[T]) fillTenant(ctx context.Context) error {
+	m, err := i.core.registry.Get(new(T))
+	if err != nil {
+		return err
+	}
+	tenant, ok, err := tenantOf(ctx, m)
+	if err != nil || !ok {
+		return err
+	}
+	tv := reflect.ValueOf(tenant)
+	for _, val := range i.values {
+		if m.Tenant.Value(reflect.ValueOf(val).Elem()).IsZero() {
+			m.Tenant.Settable(reflect.ValueOf(val).Elem()).Set(tv)
+		}
+	}
 	return nil
 }
 
@@ -140,21 +272,69 @@ func (i *Insertor[T]) Columns(cols ...string) *Insertor[T] {
 	return i
 }
 
+func (i *Insertor[T]) exec(ctx *middleware.Context) (sql.Result, error) {
+	if ev, ok := evaluatorOf(i.sess); ok {
+		if i.onConflict != nil {
+			return nil, errs.ErrUnsupported
+		}
+		return ev.Exec(ctx.Ctx, &i.builder.stmt)
+	}
+	rule, ok := shardingRuleOf[T]()
+	if !ok {
+		return i.sess.execContext(ctx.Ctx, ctx.Statement, ctx.Args...)
+	}
+	m, err := i.core.registry.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	// group the values by destination, keeping their order
+	accessor := i.core.accessor(m)
+	dsts := make([]ShardDst, 0, 4)
+	groups := make([][]*T, 0, 4)
+	for _, val := range i.values {
+		key, err := fieldValues(accessor, val, []string{rule.Key})
+		if err != nil {
+			return nil, err
+		}
+		dst, err := rule.Algorithm.Shard(key[0])
+		if err != nil {
+			return nil, err
+		}
+		idx := slices.Index(dsts, dst)
+		if idx < 0 {
+			idx = len(dsts)
+			dsts = append(dsts, dst)
+			groups = append(groups, make([]*T, 0, 4))
+		}
+		groups[idx] = append(groups[idx], val)
+	}
+	values := i.values
+	defer func() {
+		i.values, i.shardTable = values, ""
+	}()
+	return execShards(ctx, i.sess, dsts, func(ctx *middleware.Context, idx int, dst ShardDst) error {
+		i.values, i.shardTable = groups[idx], dst.Table
+		return i.build(ctx)
+	})
+}
+
 var _ middleware.Handler = (&Insertor[any]{}).handleExec
 
+// handleExec expects ctx to hold the statement built by Exec.
 func (i *Insertor[T]) handleExec(ctx *middleware.Context) *middleware.Result {
-	err := i.Build(ctx)
+	res, err := i.exec(ctx)
 	if err != nil {
 		return &middleware.Result{
 			Res: nil,
 			Err: err,
 		}
 	}
-	res, err := i.sess.execContext(ctx.Ctx, ctx.Statement, ctx.Args...)
-	if err != nil {
-		return &middleware.Result{
-			Res: nil,
-			Err: err,
+	for _, val := range i.values {
+		if err = afterInsert(ctx.Ctx, i.sess, val); err != nil {
+			return &middleware.Result{
+				Res: nil,
+				Err: err,
+			}
 		}
 	}
 	return &middleware.Result{
@@ -166,12 +346,31 @@ func (i *Insertor[T]) handleExec(ctx *middleware.Context) *middleware.Result {
 	}
 }
 func (i *Insertor[T]) Exec(ctx *middleware.Context) *ExecResult {
+	// run on a copy, i may be shared by several goroutines
+	c := *i
+	i = &c
 	ctx.Type = middleware.OpExec
-	root := i.handleExec
-	for idx := len(i.core.mdls) - 1; idx >= 0; idx-- {
-		root = i.core.mdls[idx](root)
+	for _, val := range i.values {
+		if err := beforeInsert(ctx.Ctx, i.sess, val); err != nil {
+			return &ExecResult{
+				res: nil,
+				err: err,
+			}
+		}
+	}
+	if err := i.build(ctx); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
+	}
+	if err := i.fillTenant(ctx.Ctx); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
 	}
-	res := root(ctx)
+	res := i.core.run(ctx, i.opts, i.handleExec)
 	if res.Err != nil {
 		return &ExecResult{
 			res: nil,
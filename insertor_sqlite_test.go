@@ -1,11 +1,9 @@
 package go_orm
 
 import (
-	"context"
 	"database/sql"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/kisara71/go-orm/errs"
-	"github.com/kisara71/go-orm/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -108,16 +106,12 @@ func TestInsertor_Build_SQLite(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := &middleware.Context{Ctx: context.Background()}
-			err := tc.builder.Build(ctx)
+			q, err := tc.builder.Build()
 			assert.Equal(t, tc.wantErr, err)
 			if err != nil {
 				return
 			}
-			assert.Equal(t, tc.wantQuery, &Query{
-				SQL:  ctx.Statement,
-				Args: ctx.Args,
-			})
+			assert.Equal(t, tc.wantQuery, q)
 		})
 	}
 }
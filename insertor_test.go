@@ -144,16 +144,12 @@ func TestInsertor_Mysql_Build(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := &middleware.Context{Ctx: context.Background()}
-			err := tc.builder.Build(ctx)
+			q, err := tc.builder.Build()
 			assert.Equal(t, tc.wantErr, err)
 			if err != nil {
 				return
 			}
-			assert.Equal(t, tc.wantQuery, &Query{
-				SQL:  ctx.Statement,
-				Args: ctx.Args,
-			})
+			assert.Equal(t, tc.wantQuery, q)
 		})
 	}
 }
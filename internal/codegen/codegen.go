@@ -0,0 +1,414 @@
+// Package codegen is the driver shared by the commands generating code for
+// the structs of Go files, go-orm-cols and go-orm-accessor. The fields are
+// read as model.Registry reads them.
+package codegen
+
+import (
+	"flag"
+	"fmt"
+	"github.com/kisara71/go-orm/model"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Generator returns the source generated for the structs named names of the
+// Go files, or for all of them when names is empty.
+type Generator func(files []string, names []string) ([]byte, error)
+
+// Main runs the command cmd: it reads the -type and -out flags and writes
+// the source generated for the files given as arguments, or for $GOFILE
+// under go generate. The output file defaults to the first file with its
+// extension replaced by suffix.
+func Main(cmd string, suffix string, generate Generator) {
+	var (
+		typeNames = flag.String("type", "", "comma separated struct names, every struct when empty")
+		out       = flag.String("out", "", "output file, defaults to <file>"+suffix)
+	)
+	flag.Parse()
+	if err := run(cmd, suffix, generate, *typeNames, *out, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd, suffix string, generate Generator, typeNames, out string, files []string) error {
+	if len(files) == 0 {
+		gofile := os.Getenv("GOFILE")
+		if gofile == "" {
+			return fmt.Errorf("%s: no input files and GOFILE is not set", cmd)
+		}
+		files = []string{gofile}
+	}
+	if out == "" {
+		out = strings.TrimSuffix(files[0], filepath.Ext(files[0])) + suffix
+	}
+	names := make([]string, 0, 4)
+	for _, n := range strings.Split(typeNames, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	src, err := generate(files, names)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
+
+// Package holds the structs of the parsed files.
+type Package struct {
+	Name string
+	// Structs are the structs asked for, in declaration order
+	Structs []*Struct
+	cmd     string
+	all     map[string]*Struct
+	// columns are the types implementing sql.Scanner or driver.Valuer,
+	// which the registry reads as columns when embedded
+	columns map[string]bool
+	// names caches the names of the imported packages by import path
+	names map[string]string
+}
+
+// Struct is a struct type declared in the parsed files.
+type Struct struct {
+	Name string
+	Type *ast.StructType
+	file *file
+}
+
+// file is a parsed file, the imports are resolved from its directory.
+type file struct {
+	dir     string
+	imports []*ast.ImportSpec
+}
+
+// Field is a column of a struct.
+type Field struct {
+	// Name is the name of the field, promoted from the embedded structs
+	Name   string
+	Column string
+	// Path selects the field from the struct, through the embedded structs
+	Path string
+	Type ast.Expr
+	// Owner is the struct declaring the field
+	Owner *Struct
+	// Embeds are the embedded pointers on Path, outermost first
+	Embeds []Embed
+}
+
+// Embed is an embedded pointer to the struct Type, selected by Path.
+type Embed struct {
+	Path string
+	Type string
+}
+
+// Parse parses the files and keeps the structs named names, or all of them
+// when names is empty. The errors are prefixed by cmd.
+func Parse(cmd string, filenames []string, names []string) (*Package, error) {
+	fset := token.NewFileSet()
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+	}
+	p := &Package{
+		cmd:     cmd,
+		all:     make(map[string]*Struct, 8),
+		columns: make(map[string]bool, 4),
+		names:   make(map[string]string, 4),
+	}
+	for _, filename := range filenames {
+		f, err := parser.ParseFile(fset, filename, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if p.Name == "" {
+			p.Name = f.Name.Name
+		}
+		fl := &file{dir: filepath.Dir(filename), imports: f.Imports}
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok {
+				if recv := receiverOf(fd); recv != "" && columnMethod(fd) {
+					p.columns[recv] = true
+				}
+				continue
+			}
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok || ts.TypeParams != nil {
+					continue
+				}
+				s := &Struct{Name: ts.Name.Name, Type: st, file: fl}
+				p.all[s.Name] = s
+				if len(wanted) == 0 || wanted[s.Name] {
+					p.Structs = append(p.Structs, s)
+				}
+			}
+		}
+	}
+	missing := make([]string, 0, len(wanted))
+	for n := range wanted {
+		if _, ok := p.all[n]; !ok {
+			missing = append(missing, n)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%s: struct types not found: %s", p.cmd, strings.Join(missing, ", "))
+	}
+	return p, nil
+}
+
+// Fields returns the columns of s, a name or a column defined twice is an
+// error as for the registry. The fields of the structs embedded without
+// tag are promoted. Those of the structs of other packages cannot be read,
+// embedding them without tag is an error, but for times and the Null types
+// of database/sql which the registry reads as columns.
+func (p *Package) Fields(s *Struct) ([]Field, error) {
+	res := make([]Field, 0, len(s.Type.Fields.List))
+	if err := p.fields(s, s.Name, "", nil, &res, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(res))
+	cols := make(map[string]bool, len(res))
+	for _, f := range res {
+		if names[f.Name] || cols[f.Column] {
+			return nil, fmt.Errorf("%s: %s.%s: field or column defined twice", p.cmd, s.Name, f.Name)
+		}
+		names[f.Name], cols[f.Column] = true, true
+	}
+	return res, nil
+}
+
+// fields adds the fields of s, found at path from the root struct named
+// root. visiting guards against recursive embedding.
+func (p *Package) fields(s *Struct, root, path string, embeds []Embed, res *[]Field, visiting map[string]bool) error {
+	if visiting[s.Name] {
+		return fmt.Errorf("%s: %s: %s embeds itself", p.cmd, root, s.Name)
+	}
+	visiting[s.Name] = true
+	defer delete(visiting, s.Name)
+	for _, field := range s.Type.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			raw, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return err
+			}
+			tag = reflect.StructTag(raw)
+		}
+		tags, err := model.ParseTag(tag)
+		if err != nil {
+			return fmt.Errorf("%s: %s.%s: %w", p.cmd, root, fieldName(field), err)
+		}
+		if len(field.Names) == 0 {
+			name := fieldName(field)
+			embedded, ptr := p.embedded(field.Type)
+			if embedded == nil && len(tags) == 0 {
+				foreign, err := p.foreign(s, field.Type)
+				if err != nil {
+					return err
+				}
+				if foreign {
+					return fmt.Errorf("%s: %s.%s: embedded type of another package, tag it to read it as a column",
+						p.cmd, root, name)
+				}
+			}
+			if embedded != nil && len(tags) == 0 {
+				sub := path + name
+				subEmbeds := embeds
+				if ptr {
+					subEmbeds = append(append([]Embed(nil), embeds...), Embed{Path: sub, Type: embedded.Name})
+				}
+				if err = p.fields(embedded, root, sub+".", subEmbeds, res, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+			*res = append(*res, Field{
+				Name: name, Column: model.ColumnOf(name, tags), Path: path + name,
+				Type: field.Type, Owner: s, Embeds: embeds,
+			})
+			continue
+		}
+		for _, ident := range field.Names {
+			*res = append(*res, Field{
+				Name: ident.Name, Column: model.ColumnOf(ident.Name, tags), Path: path + ident.Name,
+				Type: field.Type, Owner: s, Embeds: embeds,
+			})
+		}
+	}
+	return nil
+}
+
+// embedded returns the struct declared in the parsed files which typ, the
+// type of an embedded field, refers to, nil when there is none or when it
+// is read as a column.
+func (p *Package) embedded(typ ast.Expr) (*Struct, bool) {
+	ptr := false
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ, ptr = star.X, true
+	}
+	ident, ok := typ.(*ast.Ident)
+	if !ok || p.columns[ident.Name] {
+		return nil, false
+	}
+	return p.all[ident.Name], ptr
+}
+
+// foreign tells whether typ, the type of an embedded field of s, is declared
+// in another package and not known to be read as a column by the registry.
+func (p *Package) foreign(s *Struct, typ ast.Expr) (bool, error) {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	sel, ok := typ.(*ast.SelectorExpr)
+	if !ok {
+		return false, nil
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return true, nil
+	}
+	spec, ok, err := p.Import(s, pkg.Name)
+	if err != nil || !ok {
+		return true, err
+	}
+	switch path, _ := strconv.Unquote(ImportPath(spec)); path {
+	case "time":
+		return sel.Sel.Name != "Time", nil
+	case "database/sql":
+		return !strings.HasPrefix(sel.Sel.Name, "Null"), nil
+	}
+	return true, nil
+}
+
+// fieldName returns the name of the first field declared by field, the
+// type name of an embedded one.
+func fieldName(field *ast.Field) string {
+	if len(field.Names) > 0 {
+		return field.Names[0].Name
+	}
+	typ := field.Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if sel, ok := typ.(*ast.SelectorExpr); ok {
+		return sel.Sel.Name
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// receiverOf returns the type name of the receiver of fd, "" for functions.
+func receiverOf(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	typ := fd.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// columnMethod tells whether fd is the method of sql.Scanner, Scan(src any)
+// error, or the one of driver.Valuer, Value() (driver.Value, error) on a
+// value receiver. The Scan methods generated by go-orm-accessor are not.
+func columnMethod(fd *ast.FuncDecl) bool {
+	params, results := fd.Type.Params, fd.Type.Results
+	switch fd.Name.Name {
+	case "Scan":
+		return params.NumFields() == 1 && isAny(params.List[0].Type) &&
+			results.NumFields() == 1 && isIdent(results.List[0].Type, "error")
+	case "Value":
+		if _, ptr := fd.Recv.List[0].Type.(*ast.StarExpr); ptr || params.NumFields() != 0 || results.NumFields() != 2 {
+			return false
+		}
+		sel, ok := results.List[0].Type.(*ast.SelectorExpr)
+		return ok && sel.Sel.Name == "Value" && isIdent(results.List[len(results.List)-1].Type, "error")
+	}
+	return false
+}
+
+// isAny tells whether typ is any or interface{}.
+func isAny(typ ast.Expr) bool {
+	if it, ok := typ.(*ast.InterfaceType); ok {
+		return it.Methods.NumFields() == 0
+	}
+	return isIdent(typ, "any")
+}
+
+func isIdent(typ ast.Expr, name string) bool {
+	ident, ok := typ.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+// Import returns the import spec, as it should be written in a generated
+// file, of the package the file declaring s refers to as name. ok is false
+// when the file imports no such package.
+func (p *Package) Import(s *Struct, name string) (spec string, ok bool, err error) {
+	for _, imp := range s.file.imports {
+		if imp.Name != nil && imp.Name.Name == name {
+			return imp.Name.Name + " " + imp.Path.Value, true, nil
+		}
+	}
+	for _, imp := range s.file.imports {
+		if imp.Name != nil {
+			continue
+		}
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return "", false, err
+		}
+		pkgName, err := p.packageName(path, s.file.dir)
+		if err != nil {
+			return "", false, err
+		}
+		if pkgName == name {
+			return imp.Path.Value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// packageName returns the name in the package clause of the package path
+// imported from dir, which may differ from the last element of the path,
+// e.g. yaml for gopkg.in/yaml.v3.
+func (p *Package) packageName(path, dir string) (string, error) {
+	if name, ok := p.names[path]; ok {
+		return name, nil
+	}
+	// the go command resolving the path runs in dir, the module of the
+	// file may not be the one of the working directory
+	ctxt := build.Default
+	ctxt.Dir = dir
+	pkg, err := ctxt.Import(path, dir, 0)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", p.cmd, err)
+	}
+	p.names[path] = pkg.Name
+	return pkg.Name, nil
+}
+
+// ImportPath returns the quoted path of an import spec.
+func ImportPath(spec string) string {
+	return spec[strings.IndexByte(spec, '"'):]
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/kisara71/go-orm/middleware"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store keeps query results. Entries are tagged with the tables they were
+// read from so that a write to one of those tables drops them.
+type Store interface {
+	Get(ctx context.Context, key string) (any, bool)
+	Set(ctx context.Context, key string, val any, ttl time.Duration, tables ...string)
+	// Invalidate drops the entries read from tables, every entry when no
+	// table is given.
+	Invalidate(ctx context.Context, tables ...string)
+}
+
+type ttlKey struct{}
+
+// WithTTL overrides the TTL of the results cached by queries run with ctx.
+func WithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlKey{}, ttl)
+}
+
+// Bypass makes queries run with ctx skip the cache, neither reading nor
+// storing results, see middleware.WithoutCache.
+func Bypass(ctx context.Context) context.Context {
+	return middleware.WithoutCache(ctx)
+}
+
+// MiddleWareBuilder caches the results of OpQuery statements. Cached entities
+// are shared between callers and must not be modified. Writes made inside a
+// transaction invalidate entries before the commit, so queries run in a
+// transaction should Bypass the cache.
+//
+// Raw statements name no table: raw queries are not cached and raw writes
+// drop every entry.
+type MiddleWareBuilder struct {
+	store Store
+	ttl   time.Duration
+	gens  *generations
+}
+
+func New(store Store, ttl time.Duration) MiddleWareBuilder {
+	return MiddleWareBuilder{
+		store: store,
+		ttl:   ttl,
+		gens:  &generations{tables: make(map[string]uint64, 8)},
+	}
+}
+
+// generations counts the writes to each table, so that a query which read
+// a table before a write does not store its result after the write dropped
+// the entries. Writes and stores are serialized by mu.
+type generations struct {
+	mu     sync.Mutex
+	all    uint64
+	tables map[string]uint64
+}
+
+// of returns the generations of tables, the one of the raw writes first.
+// The caller holds mu.
+func (g *generations) of(tables []string) []uint64 {
+	res := make([]uint64, 0, len(tables)+1)
+	res = append(res, g.all)
+	for _, t := range tables {
+		res = append(res, g.tables[t])
+	}
+	return res
+}
+
+func (g *generations) snapshot(tables []string) []uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.of(tables)
+}
+
+// set stores val unless one of tables was written since snapshot.
+func (m MiddleWareBuilder) set(ctx context.Context, snapshot []uint64, key string, val any,
+	ttl time.Duration, tables []string) {
+	m.gens.mu.Lock()
+	defer m.gens.mu.Unlock()
+	if slices.Equal(snapshot, m.gens.of(tables)) {
+		m.store.Set(ctx, key, val, ttl, tables...)
+	}
+}
+
+// invalidate drops the entries of tables, every entry when there is none.
+func (m MiddleWareBuilder) invalidate(ctx context.Context, tables []string) {
+	m.gens.mu.Lock()
+	defer m.gens.mu.Unlock()
+	if len(tables) == 0 {
+		m.gens.all++
+	}
+	for _, t := range tables {
+		m.gens.tables[t]++
+	}
+	m.store.Invalidate(ctx, tables...)
+}
+
+func (m MiddleWareBuilder) Build() middleware.Middleware {
+	return func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			if ctx.Type == middleware.OpExec {
+				res := next(ctx)
+				if res.Err == nil {
+					m.invalidate(ctx.Ctx, ctx.Tables)
+				}
+				return res
+			}
+			if len(ctx.Tables) == 0 {
+				return next(ctx)
+			}
+			if middleware.CacheSkipped(ctx.Ctx) {
+				return next(ctx)
+			}
+			key := keyOf(ctx)
+			snapshot := m.gens.snapshot(ctx.Tables)
+			if val, ok := m.store.Get(ctx.Ctx, key); ok {
+				return &middleware.Result{
+					Res: val,
+					Err: nil,
+				}
+			}
+			res := next(ctx)
+			if res.Err != nil {
+				return res
+			}
+			ttl := m.ttl
+			if t, ok := ctx.Ctx.Value(ttlKey{}).(time.Duration); ok {
+				ttl = t
+			}
+			m.set(ctx.Ctx, snapshot, key, res.Res, ttl, ctx.Tables)
+			return res
+		}
+	}
+}
+
+// keyOf tells Get from GetMulti since both run the same statement, and the
+// models scanned from the same statement apart.
+func keyOf(ctx *middleware.Context) string {
+	var sb strings.Builder
+	if ctx.Single {
+		sb.WriteString("one:")
+	} else {
+		sb.WriteString("multi:")
+	}
+	if ctx.Model != nil && ctx.Model.Type != nil {
+		sb.WriteString(ctx.Model.Type.PkgPath())
+		sb.WriteByte('.')
+		sb.WriteString(ctx.Model.Type.String())
+		sb.WriteByte(':')
+	}
+	sb.WriteString(ctx.Statement)
+	for _, arg := range ctx.Args {
+		_, _ = fmt.Fprintf(&sb, "\x00%T:%v", arg, arg)
+	}
+	return sb.String()
+}
@@ -0,0 +1,258 @@
+package cache_test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	orm "github.com/kisara71/go-orm"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/middleware/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type TestModel struct {
+	ID   int64
+	Name string
+}
+
+func TestMiddleWareBuilder(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := orm.OpenDB(mockDB, orm.WithDialect(orm.MySQLDialect))
+	store := cache.NewLRU(16)
+	db.Use(cache.New(store, time.Minute).Build())
+
+	selectByID := "SELECT * FROM `test_model` WHERE `id` = ?;"
+	mock.ExpectQuery(selectByID).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom"))
+	mock.ExpectQuery(selectByID).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom"))
+	mock.ExpectQuery(selectByID).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom"))
+	mock.ExpectExec("UPDATE `test_model` SET `name` = ?;").WithArgs("jerry").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(selectByID).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "jerry"))
+
+	get := func(ctx context.Context) *TestModel {
+		res, err := orm.NewSelector[TestModel](db).Where(orm.C("ID").Eq(1)).
+			Get(&middleware.Context{Ctx: ctx})
+		require.NoError(t, err)
+		return res
+	}
+	getMulti := func(ctx context.Context) []*TestModel {
+		res, err := orm.NewSelector[TestModel](db).Where(orm.C("ID").Eq(1)).
+			GetMulti(&middleware.Context{Ctx: ctx})
+		require.NoError(t, err)
+		return res
+	}
+
+	ctx := context.Background()
+	// first query misses, the second one is served from the cache
+	assert.Equal(t, "tom", get(ctx).Name)
+	assert.Equal(t, "tom", get(ctx).Name)
+	// GetMulti runs the same statement but is cached apart from Get
+	assert.Len(t, getMulti(ctx), 1)
+	assert.Len(t, getMulti(ctx), 1)
+	// bypass always reaches the database
+	assert.Equal(t, "tom", get(cache.Bypass(ctx)).Name)
+	assert.Equal(t, 2, store.Len())
+
+	// a write to the table drops its entries
+	res := orm.NewUpdater[TestModel](db).Set(orm.Assign("Name", "jerry")).
+		Exec(&middleware.Context{Ctx: ctx})
+	require.NoError(t, res.Err())
+	assert.Equal(t, 0, store.Len())
+	assert.Equal(t, "jerry", get(ctx).Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type TestModelView struct {
+	ID   int64
+	Name string
+}
+
+// TestMiddleWareBuilder_Models runs the same statement scanned into two
+// models, their results are cached apart.
+func TestMiddleWareBuilder_Models(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := orm.OpenDB(mockDB, orm.WithDialect(orm.MySQLDialect))
+	db.Use(cache.New(cache.NewLRU(16), time.Minute).Build())
+
+	query := "SELECT * FROM `test_model`;"
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom"))
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom"))
+
+	ctx := &middleware.Context{Ctx: context.Background()}
+	models, err := orm.NewSelector[TestModel](db).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{{ID: 1, Name: "tom"}}, models)
+	views, err := orm.NewSelector[TestModelView](db).From(orm.TableOf(&TestModel{})).
+		GetMulti(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModelView{{ID: 1, Name: "tom"}}, views)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddleWareBuilder_TTL(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := orm.OpenDB(mockDB, orm.WithDialect(orm.MySQLDialect))
+	db.Use(cache.New(cache.NewLRU(16), time.Minute).Build())
+
+	query := "SELECT * FROM `test_model`;"
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	ctx := cache.WithTTL(context.Background(), time.Millisecond)
+	for i := 0; i < 2; i++ {
+		_, err = orm.NewSelector[TestModel](db).GetMulti(&middleware.Context{Ctx: ctx})
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestMiddleWareBuilder_Stale writes the table while the query runs: the
+// result read before the write is not stored.
+func TestMiddleWareBuilder_Stale(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := orm.OpenDB(mockDB, orm.WithDialect(orm.MySQLDialect))
+	store := cache.NewLRU(16)
+	db.Use(cache.New(store, time.Minute).Build())
+
+	query := "SELECT * FROM `test_model`;"
+	mock.ExpectExec("UPDATE `test_model` SET `name` = ?;").WithArgs("jerry").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom"))
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "jerry"))
+
+	ctx := context.Background()
+	write := orm.WithMiddlewares(func(next middleware.Handler) middleware.Handler {
+		return func(mctx *middleware.Context) *middleware.Result {
+			res := orm.NewUpdater[TestModel](db).Set(orm.Assign("Name", "jerry")).
+				Exec(&middleware.Context{Ctx: ctx})
+			require.NoError(t, res.Err())
+			return next(mctx)
+		}
+	})
+	_, err = orm.NewSelector[TestModel](db).With(write).GetMulti(&middleware.Context{Ctx: ctx})
+	require.NoError(t, err)
+	assert.Equal(t, 0, store.Len())
+
+	res, err := orm.NewSelector[TestModel](db).GetMulti(&middleware.Context{Ctx: ctx})
+	require.NoError(t, err)
+	assert.Equal(t, "jerry", res[0].Name)
+	assert.Equal(t, 1, store.Len())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestMiddleWareBuilder_Raw checks that raw statements, which name no table,
+// are not cached and drop every entry when they write.
+func TestMiddleWareBuilder_Raw(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := orm.OpenDB(mockDB, orm.WithDialect(orm.MySQLDialect))
+	store := cache.NewLRU(16)
+	db.Use(cache.New(store, time.Minute).Build())
+
+	mock.ExpectQuery("SELECT * FROM `test_model`;").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom"))
+	mock.ExpectQuery("SELECT * FROM test_model").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom"))
+	mock.ExpectExec("UPDATE test_model SET name = ?").WithArgs("jerry").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := &middleware.Context{Ctx: context.Background()}
+	_, err = orm.NewSelector[TestModel](db).GetMulti(ctx)
+	require.NoError(t, err)
+	_, err = orm.RawQuery[TestModel](db, "SELECT * FROM test_model").GetMulti(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.Len())
+
+	res := orm.RawExec(db, "UPDATE test_model SET name = ?", "jerry").Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+	assert.Equal(t, 0, store.Len())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name    string
+		ops     func(l *cache.LRU)
+		key     string
+		wantVal any
+		wantOk  bool
+	}{
+		{
+			name: "hit",
+			ops: func(l *cache.LRU) {
+				l.Set(ctx, "a", 1, 0, "user")
+			},
+			key:     "a",
+			wantVal: 1,
+			wantOk:  true,
+		},
+		{
+			name: "evicted",
+			ops: func(l *cache.LRU) {
+				l.Set(ctx, "a", 1, 0, "user")
+				l.Set(ctx, "b", 2, 0, "user")
+				l.Set(ctx, "c", 3, 0, "user")
+			},
+			key: "a",
+		},
+		{
+			name: "recently used kept",
+			ops: func(l *cache.LRU) {
+				l.Set(ctx, "a", 1, 0, "user")
+				l.Set(ctx, "b", 2, 0, "user")
+				l.Get(ctx, "a")
+				l.Set(ctx, "c", 3, 0, "user")
+			},
+			key:     "a",
+			wantVal: 1,
+			wantOk:  true,
+		},
+		{
+			name: "invalidated",
+			ops: func(l *cache.LRU) {
+				l.Set(ctx, "a", 1, 0, "user", "order")
+				l.Invalidate(ctx, "order")
+			},
+			key: "a",
+		},
+		{
+			name: "invalidated all",
+			ops: func(l *cache.LRU) {
+				l.Set(ctx, "a", 1, 0, "user")
+				l.Invalidate(ctx)
+			},
+			key: "a",
+		},
+		{
+			name: "other table",
+			ops: func(l *cache.LRU) {
+				l.Set(ctx, "a", 1, 0, "user")
+				l.Invalidate(ctx, "order")
+			},
+			key:     "a",
+			wantVal: 1,
+			wantOk:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := cache.NewLRU(2)
+			tc.ops(l)
+			val, ok := l.Get(ctx, tc.key)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
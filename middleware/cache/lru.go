@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Store = &LRU{}
+
+// LRU is an in-memory Store holding at most capacity entries. A zero TTL
+// keeps the entry until it is evicted or invalidated.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+	tables   map[string]map[string]struct{}
+	now      func() time.Time
+}
+
+type entry struct {
+	key      string
+	val      any
+	expireAt time.Time
+	tables   []string
+}
+
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+		tables:   make(map[string]map[string]struct{}, 8),
+		now:      time.Now,
+	}
+}
+
+func (l *LRU) Get(_ context.Context, key string) (any, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if !e.expireAt.IsZero() && !l.now().Before(e.expireAt) {
+		l.remove(elem)
+		return nil, false
+	}
+	l.ll.MoveToFront(elem)
+	return e.val, true
+}
+
+func (l *LRU) Set(_ context.Context, key string, val any, ttl time.Duration, tables ...string) {
+	if l.capacity <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.items[key]; ok {
+		l.remove(elem)
+	}
+	e := &entry{
+		key:    key,
+		val:    val,
+		tables: tables,
+	}
+	if ttl > 0 {
+		e.expireAt = l.now().Add(ttl)
+	}
+	l.items[key] = l.ll.PushFront(e)
+	for _, t := range tables {
+		keys, ok := l.tables[t]
+		if !ok {
+			keys = make(map[string]struct{}, 4)
+			l.tables[t] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for l.ll.Len() > l.capacity {
+		l.remove(l.ll.Back())
+	}
+}
+
+func (l *LRU) Invalidate(_ context.Context, tables ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(tables) == 0 {
+		l.ll.Init()
+		clear(l.items)
+		clear(l.tables)
+		return
+	}
+	for _, t := range tables {
+		for key := range l.tables[t] {
+			if elem, ok := l.items[key]; ok {
+				l.remove(elem)
+			}
+		}
+	}
+}
+
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+func (l *LRU) remove(elem *list.Element) {
+	e := l.ll.Remove(elem).(*entry)
+	delete(l.items, e.key)
+	for _, t := range e.tables {
+		keys := l.tables[t]
+		delete(keys, e.key)
+		if len(keys) == 0 {
+			delete(l.tables, t)
+		}
+	}
+}
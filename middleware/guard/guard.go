@@ -0,0 +1,104 @@
+package guard
+
+import (
+	"fmt"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+)
+
+// MiddleWareBuilder rejects dangerous statements before they reach the
+// database. It relies on the metadata set by the builders, raw statements
+// are only checked against the deny-list.
+type MiddleWareBuilder struct {
+	large  map[string]struct{}
+	denied []rule
+}
+
+type rule struct {
+	kind   middleware.OpKind
+	tables map[string]struct{}
+}
+
+type Option func(m *MiddleWareBuilder)
+
+// WithLargeTables rejects the SELECTs reading one of tables without LIMIT.
+func WithLargeTables(tables ...string) Option {
+	return func(m *MiddleWareBuilder) {
+		for _, t := range tables {
+			m.large[t] = struct{}{}
+		}
+	}
+}
+
+// WithDenied rejects the statements of kind touching one of tables, or any
+// table when none is given.
+func WithDenied(kind middleware.OpKind, tables ...string) Option {
+	return func(m *MiddleWareBuilder) {
+		r := rule{kind: kind}
+		if len(tables) > 0 {
+			r.tables = make(map[string]struct{}, len(tables))
+			for _, t := range tables {
+				r.tables[t] = struct{}{}
+			}
+		}
+		m.denied = append(m.denied, r)
+	}
+}
+
+// New rejects UPDATE and DELETE without WHERE, unless AllowFullTable was
+// called, and applies opts.
+func New(opts ...Option) MiddleWareBuilder {
+	m := MiddleWareBuilder{
+		large: make(map[string]struct{}, 4),
+	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
+}
+
+func (m MiddleWareBuilder) Build() middleware.Middleware {
+	return func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			if err := m.check(ctx); err != nil {
+				return &middleware.Result{
+					Res: nil,
+					Err: err,
+				}
+			}
+			return next(ctx)
+		}
+	}
+}
+
+func (m MiddleWareBuilder) check(ctx *middleware.Context) error {
+	for _, r := range m.denied {
+		if r.kind != ctx.Kind {
+			continue
+		}
+		if r.tables == nil {
+			return fmt.Errorf("%w: %s", errs.ErrDenied, ctx.Kind)
+		}
+		for _, t := range ctx.Tables {
+			if _, ok := r.tables[t]; ok {
+				return fmt.Errorf("%w: %s on %s", errs.ErrDenied, ctx.Kind, t)
+			}
+		}
+	}
+	switch ctx.Kind {
+	case middleware.KindUpdate, middleware.KindDelete:
+		if !ctx.HasWhere && !ctx.FullTable {
+			return fmt.Errorf("%w: %s on %s", errs.ErrFullTable, ctx.Kind, ctx.Table())
+		}
+	case middleware.KindSelect:
+		if ctx.HasLimit {
+			return nil
+		}
+		for _, t := range ctx.Tables {
+			if _, ok := m.large[t]; ok {
+				return fmt.Errorf("%w: %s", errs.ErrNoLimit, t)
+			}
+		}
+	}
+	return nil
+}
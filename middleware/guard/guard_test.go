@@ -0,0 +1,139 @@
+package guard_test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	orm "github.com/kisara71/go-orm"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/middleware/guard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type User struct {
+	Id   int64
+	Name string
+}
+
+type AuditLog struct {
+	Id int64
+}
+
+func TestMiddleWareBuilder(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db := orm.OpenDB(mockDB, orm.WithDialect(orm.MySQLDialect))
+	db.Use(guard.New(
+		guard.WithLargeTables("user"),
+		guard.WithDenied(middleware.KindDelete, "audit_log"),
+		guard.WithDenied(middleware.KindUpsert),
+	).Build())
+
+	testCases := []struct {
+		name    string
+		mock    func()
+		run     func(ctx *middleware.Context) error
+		wantErr error
+	}{
+		{
+			name: "update without where",
+			run: func(ctx *middleware.Context) error {
+				return orm.NewUpdater[User](db).Set(orm.Assign("Name", "tom")).Exec(ctx).Err()
+			},
+			wantErr: errs.ErrFullTable,
+		},
+		{
+			name: "update with where",
+			mock: func() {
+				mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(ctx *middleware.Context) error {
+				return orm.NewUpdater[User](db).Set(orm.Assign("Name", "tom")).
+					Where(orm.C("Id").Eq(1)).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "update full table allowed",
+			mock: func() {
+				mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 10))
+			},
+			run: func(ctx *middleware.Context) error {
+				return orm.NewUpdater[User](db).Set(orm.Assign("Name", "tom")).
+					AllowFullTable().Exec(ctx).Err()
+			},
+		},
+		{
+			name: "delete without where",
+			run: func(ctx *middleware.Context) error {
+				return orm.NewDeletor[User](db).Exec(ctx).Err()
+			},
+			wantErr: errs.ErrFullTable,
+		},
+		{
+			name: "delete full table allowed",
+			mock: func() {
+				mock.ExpectExec("DELETE").WillReturnResult(sqlmock.NewResult(0, 10))
+			},
+			run: func(ctx *middleware.Context) error {
+				return orm.NewDeletor[User](db).AllowFullTable().Exec(ctx).Err()
+			},
+		},
+		{
+			name: "denied table",
+			run: func(ctx *middleware.Context) error {
+				d := orm.NewDeletor[AuditLog](db)
+				d.Where(orm.C("Id").Eq(1))
+				return d.Exec(ctx).Err()
+			},
+			wantErr: errs.ErrDenied,
+		},
+		{
+			name: "denied kind",
+			run: func(ctx *middleware.Context) error {
+				return orm.NewInsertor[AuditLog](db).Values(&AuditLog{Id: 1}).
+					OnConflict().Update(orm.C("Id")).Exec(ctx).Err()
+			},
+			wantErr: errs.ErrDenied,
+		},
+		{
+			name: "select large table without limit",
+			run: func(ctx *middleware.Context) error {
+				_, err := orm.NewSelector[User](db).Where(orm.C("Id").GT(1)).GetMulti(ctx)
+				return err
+			},
+			wantErr: errs.ErrNoLimit,
+		},
+		{
+			name: "select large table with limit",
+			mock: func() {
+				mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			run: func(ctx *middleware.Context) error {
+				_, err := orm.NewSelector[User](db).Limit(10).GetMulti(ctx)
+				return err
+			},
+		},
+		{
+			name: "select small table",
+			mock: func() {
+				mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			run: func(ctx *middleware.Context) error {
+				_, err := orm.NewSelector[AuditLog](db).GetMulti(ctx)
+				return err
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.mock != nil {
+				tc.mock()
+			}
+			err := tc.run(&middleware.Context{Ctx: context.Background()})
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"errors"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// SlogBuilder logs every statement with its duration and outcome. Failed
+// statements are logged at error level, the ones slower than the threshold
+// at warn level and the others at the configured level.
+type SlogBuilder struct {
+	logger *slog.Logger
+	level  slog.Level
+	slow   time.Duration
+	redact func(table string, column string) bool
+	render func(statement string, args []any) (string, error)
+}
+
+type SlogOption func(b *SlogBuilder)
+
+// WithLevel sets the level of successful statements, info by default.
+func WithLevel(level slog.Level) SlogOption {
+	return func(b *SlogBuilder) {
+		b.level = level
+	}
+}
+
+// WithSlowThreshold logs the statements taking longer than threshold at warn
+// level.
+func WithSlowThreshold(threshold time.Duration) SlogOption {
+	return func(b *SlogBuilder) {
+		b.slow = threshold
+	}
+}
+
+// WithRedactedColumns hides the args bound to the columns, given as "column"
+// for every table or as "table.column". The args bound to no known column,
+// as the ones of raw statements, are hidden as well.
+func WithRedactedColumns(cols ...string) SlogOption {
+	set := make(map[string]struct{}, len(cols))
+	for _, col := range cols {
+		set[strings.ToLower(col)] = struct{}{}
+	}
+	return WithRedactFunc(func(table string, column string) bool {
+		column = strings.ToLower(column)
+		if _, ok := set[column]; ok {
+			return true
+		}
+		_, ok := set[strings.ToLower(table)+"."+column]
+		return ok
+	})
+}
+
+// WithRedactFunc hides the args bound to the columns for which fn returns
+// true, and the args bound to no known column.
+func WithRedactFunc(fn func(table string, column string) bool) SlogOption {
+	return func(b *SlogBuilder) {
+		b.redact = fn
+	}
+}
+
+// WithInterpolation logs the statements with their args, redacted ones
+// included, interpolated by render instead of logging the args apart, e.g.
+//
+//	log.WithInterpolation(func(statement string, args []any) (string, error) {
+//		return orm.Render(orm.MySQLDialect, statement, args)
+//	})
+func WithInterpolation(render func(statement string, args []any) (string, error)) SlogOption {
+	return func(b *SlogBuilder) {
+		b.render = render
+	}
+}
+
+func NewSlog(logger *slog.Logger, opts ...SlogOption) SlogBuilder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	b := SlogBuilder{
+		logger: logger,
+		level:  slog.LevelInfo,
+	}
+	for _, opt := range opts {
+		opt(&b)
+	}
+	return b
+}
+
+func (b SlogBuilder) Build() middleware.Middleware {
+	return func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			start := time.Now()
+			res := next(ctx)
+			duration := time.Since(start)
+
+			level := b.level
+			if b.slow > 0 && duration > b.slow {
+				level = slog.LevelWarn
+			}
+			attrs := make([]slog.Attr, 0, 7)
+			attrs = append(attrs,
+				slog.String("op", ctx.Kind.String()),
+				slog.String("table", ctx.Table()),
+			)
+			attrs = append(attrs, b.statement(ctx)...)
+			attrs = append(attrs, slog.Duration("duration", duration))
+			if res.Err != nil && !errors.Is(res.Err, errs.ErrNoRecord) {
+				level = slog.LevelError
+				attrs = append(attrs, slog.String("error", res.Err.Error()))
+			} else if rows, ok := res.Rows(); ok {
+				attrs = append(attrs, slog.Int64("rows", rows))
+			}
+			b.logger.LogAttrs(ctx.Ctx, level, "sql", attrs...)
+			return res
+		}
+	}
+}
+
+// statement returns the statement and args attributes, or the interpolated
+// statement alone. Statements failing to render are logged as usual.
+func (b SlogBuilder) statement(ctx *middleware.Context) []slog.Attr {
+	args := b.args(ctx)
+	if b.render != nil {
+		if stmt, err := b.render(ctx.Statement, args); err == nil {
+			return []slog.Attr{slog.String("statement", stmt)}
+		}
+	}
+	return []slog.Attr{
+		slog.String("statement", ctx.Statement),
+		slog.Any("args", args),
+	}
+}
+
+func (b SlogBuilder) args(ctx *middleware.Context) []any {
+	if b.redact == nil {
+		return ctx.Args
+	}
+	var args []any
+	for idx := range ctx.Args {
+		// the columns are unknown for raw statements, LIMIT or raw
+		// expressions, which may hold anything
+		if idx < len(ctx.ArgColumns) && ctx.ArgColumns[idx] != "" &&
+			!b.redact(ctx.Table(), ctx.ArgColumns[idx]) {
+			continue
+		}
+		if args == nil {
+			args = make([]any, len(ctx.Args))
+			copy(args, ctx.Args)
+		}
+		args[idx] = redacted
+	}
+	if args == nil {
+		return ctx.Args
+	}
+	return args
+}
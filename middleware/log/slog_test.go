@@ -0,0 +1,206 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type rowsAffected int64
+
+func (r rowsAffected) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+func TestSlogBuilder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []SlogOption
+		ctx     *middleware.Context
+		res     *middleware.Result
+		delay   time.Duration
+		want    map[string]any
+		wantNil []string
+	}{
+		{
+			name: "exec",
+			ctx: &middleware.Context{
+				Kind:       middleware.KindUpdate,
+				Tables:     []string{"user"},
+				Statement:  "UPDATE `user` SET `name` = ?;",
+				Args:       []any{"tom"},
+				ArgColumns: []string{"name"},
+			},
+			res: &middleware.Result{Res: rowsAffected(3)},
+			want: map[string]any{
+				"level":     "INFO",
+				"op":        "update",
+				"table":     "user",
+				"statement": "UPDATE `user` SET `name` = ?;",
+				"args":      []any{"tom"},
+				"rows":      float64(3),
+			},
+			wantNil: []string{"error"},
+		},
+		{
+			name: "query",
+			opts: []SlogOption{WithLevel(slog.LevelDebug)},
+			ctx: &middleware.Context{
+				Kind:   middleware.KindSelect,
+				Tables: []string{"user"},
+			},
+			res: &middleware.Result{Res: []*int{nil, nil}},
+			want: map[string]any{
+				"level": "DEBUG",
+				"op":    "select",
+				"rows":  float64(2),
+			},
+		},
+		{
+			name: "no record",
+			ctx:  &middleware.Context{Kind: middleware.KindSelect},
+			res:  &middleware.Result{Err: errs.ErrNoRecord},
+			want: map[string]any{
+				"level": "INFO",
+			},
+			wantNil: []string{"error"},
+		},
+		{
+			name: "error",
+			ctx:  &middleware.Context{Kind: middleware.KindDelete},
+			res:  &middleware.Result{Err: sql.ErrConnDone},
+			want: map[string]any{
+				"level": "ERROR",
+				"error": sql.ErrConnDone.Error(),
+			},
+			wantNil: []string{"rows"},
+		},
+		{
+			name:  "slow",
+			opts:  []SlogOption{WithSlowThreshold(time.Millisecond)},
+			ctx:   &middleware.Context{Kind: middleware.KindSelect},
+			res:   &middleware.Result{Res: new(int)},
+			delay: 5 * time.Millisecond,
+			want: map[string]any{
+				"level": "WARN",
+				"rows":  float64(1),
+			},
+		},
+		{
+			name: "redacted",
+			opts: []SlogOption{WithRedactedColumns("password", "user.Email")},
+			ctx: &middleware.Context{
+				Kind:       middleware.KindInsert,
+				Tables:     []string{"user"},
+				Args:       []any{"tom", "secret", "tom@example.com", int64(10)},
+				ArgColumns: []string{"name", "password", "email", ""},
+			},
+			res: &middleware.Result{Res: rowsAffected(1)},
+			want: map[string]any{
+				"args": []any{"tom", redacted, redacted, redacted},
+			},
+		},
+		{
+			name: "redacted raw",
+			opts: []SlogOption{WithRedactedColumns("password")},
+			ctx: &middleware.Context{
+				Kind:      middleware.KindRaw,
+				Statement: "UPDATE `user` SET `password` = ? WHERE `id` = ?;",
+				Args:      []any{"secret", int64(10)},
+			},
+			res: &middleware.Result{Res: rowsAffected(1)},
+			want: map[string]any{
+				"args": []any{redacted, redacted},
+			},
+		},
+		{
+			name: "not redacted",
+			ctx: &middleware.Context{
+				Kind:      middleware.KindRaw,
+				Statement: "UPDATE `user` SET `password` = ? WHERE `id` = ?;",
+				Args:      []any{"secret", int64(10)},
+			},
+			res: &middleware.Result{Res: rowsAffected(1)},
+			want: map[string]any{
+				"args": []any{"secret", float64(10)},
+			},
+		},
+		{
+			name: "interpolated",
+			opts: []SlogOption{WithInterpolation(render), WithRedactedColumns("password")},
+			ctx: &middleware.Context{
+				Kind:       middleware.KindUpdate,
+				Statement:  "UPDATE `user` SET `name` = ?, `password` = ?;",
+				Args:       []any{"tom", "secret"},
+				ArgColumns: []string{"name", "password"},
+			},
+			res: &middleware.Result{Res: rowsAffected(1)},
+			want: map[string]any{
+				"statement": "UPDATE `user` SET `name` = 'tom', `password` = '[REDACTED]';",
+			},
+			wantNil: []string{"args"},
+		},
+		{
+			name: "interpolation failed",
+			opts: []SlogOption{WithInterpolation(render)},
+			ctx: &middleware.Context{
+				Kind:      middleware.KindSelect,
+				Statement: "SELECT * FROM `user` WHERE `id` = ?;",
+				Args:      []any{int64(3)},
+			},
+			res: &middleware.Result{Res: new(int)},
+			want: map[string]any{
+				"statement": "SELECT * FROM `user` WHERE `id` = ?;",
+				"args":      []any{float64(3)},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			mdl := NewSlog(logger, tc.opts...).Build()
+			tc.ctx.Ctx = context.Background()
+			args := append([]any(nil), tc.ctx.Args...)
+			res := mdl(func(ctx *middleware.Context) *middleware.Result {
+				time.Sleep(tc.delay)
+				return tc.res
+			})(tc.ctx)
+			assert.Equal(t, tc.res, res)
+			// redaction never touches the args sent to the database
+			assert.Equal(t, args, tc.ctx.Args)
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			assert.Equal(t, "sql", record["msg"])
+			assert.Contains(t, record, "duration")
+			for k, v := range tc.want {
+				assert.Equal(t, v, record[k], k)
+			}
+			for _, k := range tc.wantNil {
+				assert.NotContains(t, record, k)
+			}
+		})
+	}
+}
+
+// render is a naive interpolation of string args, failing on the others.
+func render(statement string, args []any) (string, error) {
+	for _, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return "", errs.ErrUnsupportedType
+		}
+		statement = strings.Replace(statement, "?", "'"+s+"'", 1)
+	}
+	return statement, nil
+}
@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"context"
+	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/model"
+	"reflect"
 )
 
 type Result struct {
@@ -10,6 +12,22 @@ type Result struct {
 	Err error
 }
 
+// Rows returns the rows affected by a statement or the rows returned by a
+// query, false when unknown.
+func (r *Result) Rows() (int64, bool) {
+	if r.Res == nil {
+		return 0, false
+	}
+	if ra, ok := r.Res.(interface{ RowsAffected() (int64, error) }); ok {
+		rows, err := ra.RowsAffected()
+		return rows, err == nil
+	}
+	if v := reflect.ValueOf(r.Res); v.Kind() == reflect.Slice {
+		return int64(v.Len()), true
+	}
+	return 1, true
+}
+
 type Middleware func(handler Handler) Handler
 
 type Handler func(ctx *Context) *Result
@@ -30,12 +48,83 @@ func (o OpType) String() string {
 	return ""
 }
 
+// OpKind is the kind of statement, finer grained than OpType.
+type OpKind uint8
+
+const (
+	KindRaw OpKind = iota
+	KindSelect
+	KindInsert
+	KindUpdate
+	KindDelete
+	KindUpsert
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case KindRaw:
+		return "raw"
+	case KindSelect:
+		return "select"
+	case KindInsert:
+		return "insert"
+	case KindUpdate:
+		return "update"
+	case KindDelete:
+		return "delete"
+	case KindUpsert:
+		return "upsert"
+	}
+	return ""
+}
+
 type Context struct {
 	Ctx       context.Context
 	Model     *model.Model
 	Statement string
 	Type      OpType
-	Args      []any
+	Kind      OpKind
+	// Tables are the logical names of the tables the statement reads or
+	// writes, the table of Model first.
+	Tables []string
+	Args   []any
+	// ArgColumns holds the column each of Args is bound to, "" when the
+	// builder does not know it, e.g. for LIMIT or raw expressions.
+	ArgColumns []string
+	// Single is set when the query returns one row, as Selector.Get does.
+	Single bool
+	// HasWhere and HasLimit tell whether the statement has a WHERE or a
+	// LIMIT clause.
+	HasWhere bool
+	HasLimit bool
+	// FullTable is set by AllowFullTable on Updater and Deletor.
+	FullTable bool
+	// Attrs is shared by the middlewares of one call, see Set and Get.
+	Attrs map[string]any
+	// Tags are the labels given to the statement by the caller.
+	Tags map[string]string
+	// handler is run by Dispatch, see SetHandler
+	handler Handler
+}
+
+// Table returns the first table of the statement, or "" when unknown.
+func (c *Context) Table() string {
+	if len(c.Tables) > 0 {
+		return c.Tables[0]
+	}
+	return ""
+}
+
+func (c *Context) Set(key string, val any) {
+	if c.Attrs == nil {
+		c.Attrs = make(map[string]any, 4)
+	}
+	c.Attrs[key] = val
+}
+
+func (c *Context) Get(key string) (any, bool) {
+	val, ok := c.Attrs[key]
+	return val, ok
 }
 
 func (c *Context) SetStatement(statement string) {
@@ -44,3 +133,37 @@ func (c *Context) SetStatement(statement string) {
 func (c *Context) SetArgs(args []any) {
 	c.Args = args
 }
+
+// SetHandler sets the handler Dispatch runs for c and returns the previous
+// one.
+func (c *Context) SetHandler(h Handler) Handler {
+	prev := c.handler
+	c.handler = h
+	return prev
+}
+
+// Dispatch runs the handler set by SetHandler, it ends the chains composed
+// once and shared by the statements.
+func Dispatch(ctx *Context) *Result {
+	if ctx.handler == nil {
+		return &Result{
+			Res: nil,
+			Err: errs.ErrNoHandler,
+		}
+	}
+	return ctx.handler(ctx)
+}
+
+type noCacheKey struct{}
+
+// WithoutCache makes the statements run with ctx skip the cache middleware,
+// neither reading nor storing results.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// CacheSkipped tells whether ctx was returned by WithoutCache.
+func CacheSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(noCacheKey{}).(bool)
+	return skip
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type rowsAffected int64
+
+func (r rowsAffected) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+type errRowsAffected struct{}
+
+func (errRowsAffected) RowsAffected() (int64, error) {
+	return 0, errors.New("not supported")
+}
+
+func TestResult_Rows(t *testing.T) {
+	testCases := []struct {
+		name     string
+		res      any
+		wantRows int64
+		wantOk   bool
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:     "rows affected",
+			res:      rowsAffected(2),
+			wantRows: 2,
+			wantOk:   true,
+		},
+		{
+			name: "rows affected failed",
+			res:  errRowsAffected{},
+		},
+		{
+			name:     "slice",
+			res:      []int{1, 2, 3},
+			wantRows: 3,
+			wantOk:   true,
+		},
+		{
+			name:     "single row",
+			res:      &struct{}{},
+			wantRows: 1,
+			wantOk:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, ok := (&Result{Res: tc.res}).Rows()
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantRows, rows)
+		})
+	}
+}
@@ -1,40 +1,168 @@
 package prometheus
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"time"
 )
 
 type MiddleWareBuilder struct {
-	summary *prometheus.SummaryVec
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+	classify func(err error) string
 }
 
-func New(namespace string, subsystem string) MiddleWareBuilder {
-	summary := prometheus.NewSummaryVec(prometheus.SummaryOpts{
-		Namespace: namespace,
-		Subsystem: subsystem,
-		Name:      "go_orm_prometheus",
-		Help:      "record the sql exec duration",
-		Objectives: map[float64]float64{
-			0.5:  0.05,
-			0.8:  0.02,
-			0.9:  0.01,
-			0.99: 0.001,
-		},
-	}, []string{"type", "table"})
+type config struct {
+	name       string
+	buckets    []float64
+	registerer prometheus.Registerer
+	classify   func(err error) string
+	stats      []dbStats
+}
+
+type dbStats struct {
+	name string
+	db   StatsGetter
+}
+
+type Option func(c *config)
+
+// WithName sets the prefix of the metric names, "go_orm" by default.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithBuckets sets the buckets of the duration histogram, in seconds.
+func WithBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// WithRegisterer registers the metrics on reg instead of
+// prometheus.DefaultRegisterer.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(c *config) {
+		c.registerer = reg
+	}
+}
+
+// WithErrorClassifier sets how errors are labelled, ClassifyError by default.
+func WithErrorClassifier(fn func(err error) string) Option {
+	return func(c *config) {
+		c.classify = fn
+	}
+}
 
-	prometheus.MustRegister(summary)
-	return MiddleWareBuilder{summary: summary}
+// StatsGetter is implemented by *sql.DB and *go_orm.DB.
+type StatsGetter interface {
+	Stats() sql.DBStats
+}
+
+// WithDBStats exports the connection pool statistics of db, labelled with
+// name.
+func WithDBStats(name string, db StatsGetter) Option {
+	return func(c *config) {
+		c.stats = append(c.stats, dbStats{name: name, db: db})
+	}
+}
+
+// New creates the metrics and registers them. Registering the same metrics
+// twice reuses the ones already registered.
+func New(namespace string, subsystem string, opts ...Option) MiddleWareBuilder {
+	c := &config{
+		name:       "go_orm",
+		buckets:    prometheus.DefBuckets,
+		registerer: prometheus.DefaultRegisterer,
+		classify:   ClassifyError,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	labels := []string{"op", "table"}
+	m := MiddleWareBuilder{
+		duration: register(c.registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      c.name + "_duration_seconds",
+			Help:      "Duration of the sql statements.",
+			Buckets:   c.buckets,
+		}, labels)),
+		errors: register(c.registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      c.name + "_errors_total",
+			Help:      "Number of failed sql statements by error type.",
+		}, append(labels, "type"))),
+		inFlight: register(c.registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      c.name + "_in_flight",
+			Help:      "Number of sql statements being run.",
+		}, labels)),
+		classify: c.classify,
+	}
+	for _, st := range c.stats {
+		register(c.registerer, newStatsCollector(namespace, subsystem, c.name, st.name, st.db))
+	}
+	return m
+}
+
+func register[C prometheus.Collector](reg prometheus.Registerer, col C) C {
+	if err := reg.Register(col); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return col
 }
 
 func (m MiddleWareBuilder) Build() middleware.Middleware {
 	return func(next middleware.Handler) middleware.Handler {
 		return func(ctx *middleware.Context) *middleware.Result {
+			op, table := ctx.Kind.String(), ctx.Table()
+			inFlight := m.inFlight.WithLabelValues(op, table)
+			inFlight.Inc()
 			start := time.Now()
 			res := next(ctx)
-			m.summary.WithLabelValues(ctx.Type.String(), ctx.Model.TableName).Observe(time.Since(start).Seconds())
+			m.duration.WithLabelValues(op, table).Observe(time.Since(start).Seconds())
+			inFlight.Dec()
+			if res.Err != nil {
+				if typ := m.classify(res.Err); typ != "" {
+					m.errors.WithLabelValues(op, table, typ).Inc()
+				}
+			}
 			return res
 		}
 	}
 }
+
+// ClassifyError labels err for the errors counter. A missing record is not
+// counted.
+func ClassifyError(err error) string {
+	switch {
+	case errors.Is(err, errs.ErrNoRecord):
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return "connection"
+	case errors.Is(err, sql.ErrTxDone):
+		return "tx_done"
+	}
+	return "other"
+}
@@ -0,0 +1,95 @@
+package prometheus
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stats sql.DBStats
+
+func (s stats) Stats() sql.DBStats {
+	return sql.DBStats(s)
+}
+
+func TestMiddleWareBuilder(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New("app", "db", WithRegisterer(reg), WithBuckets(0.1, 1))
+	// constructing it again reuses the registered metrics
+	assert.NotPanics(t, func() {
+		New("app", "db", WithRegisterer(reg), WithBuckets(0.1, 1))
+	})
+
+	var inFlight float64
+	run := func(err error) {
+		ctx := &middleware.Context{
+			Ctx:    context.Background(),
+			Kind:   middleware.KindUpdate,
+			Tables: []string{"user"},
+		}
+		m.Build()(func(ctx *middleware.Context) *middleware.Result {
+			inFlight = testutil.ToFloat64(m.inFlight.WithLabelValues("update", "user"))
+			return &middleware.Result{Err: err}
+		})(ctx)
+	}
+	run(nil)
+	run(context.DeadlineExceeded)
+	run(fmt.Errorf("exec: %w", driver.ErrBadConn))
+	run(errs.ErrNoRecord)
+
+	assert.Equal(t, float64(1), inFlight)
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.inFlight.WithLabelValues("update", "user")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.duration, "app_db_go_orm_duration_seconds"))
+	require.NoError(t, testutil.CollectAndCompare(m.errors, strings.NewReader(`
+# HELP app_db_go_orm_errors_total Number of failed sql statements by error type.
+# TYPE app_db_go_orm_errors_total counter
+app_db_go_orm_errors_total{op="update",table="user",type="connection"} 1
+app_db_go_orm_errors_total{op="update",table="user",type="timeout"} 1
+`)))
+}
+
+func TestWithDBStats(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	New("", "", WithRegisterer(reg), WithName("orm"),
+		WithDBStats("primary", stats{MaxOpenConnections: 10, InUse: 2, WaitDuration: 2 * time.Second}))
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP orm_in_use_connections The number of connections currently in use.
+# TYPE orm_in_use_connections gauge
+orm_in_use_connections{db="primary"} 2
+# HELP orm_max_open_connections Maximum number of open connections to the database.
+# TYPE orm_max_open_connections gauge
+orm_max_open_connections{db="primary"} 10
+# HELP orm_wait_duration_seconds_total The total time blocked waiting for a new connection.
+# TYPE orm_wait_duration_seconds_total counter
+orm_wait_duration_seconds_total{db="primary"} 2
+`), "orm_in_use_connections", "orm_max_open_connections", "orm_wait_duration_seconds_total"))
+}
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want string
+	}{
+		{err: errs.ErrNoRecord, want: ""},
+		{err: context.DeadlineExceeded, want: "timeout"},
+		{err: context.Canceled, want: "canceled"},
+		{err: sql.ErrConnDone, want: "connection"},
+		{err: sql.ErrTxDone, want: "tx_done"},
+		{err: errs.ErrUnknownField, want: "other"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.want, ClassifyError(tc.err))
+		})
+	}
+}
@@ -1,27 +1,141 @@
 package trace
 
 import (
+	"errors"
+	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/middleware"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
+	"reflect"
+	"strings"
+	"time"
 )
 
+const instrumentationName = "github.com/kisara71/go-orm"
+
+// rowsAffectedKey is not part of the database semantic conventions.
+const rowsAffectedKey = attribute.Key("db.rows_affected")
+
 type MiddleWareBuilder struct {
-	tracer trace.Tracer
+	tracer   trace.Tracer
+	system   string
+	sanitize func(statement string) string
+	meter    metric.MeterProvider
+}
+
+type Option func(m *MiddleWareBuilder)
+
+// WithDBSystem sets the db.system attribute, e.g. "mysql".
+func WithDBSystem(system string) Option {
+	return func(m *MiddleWareBuilder) {
+		m.system = system
+	}
+}
+
+// WithSanitizer rewrites the statement before it is recorded, see Sanitize.
+func WithSanitizer(fn func(statement string) string) Option {
+	return func(m *MiddleWareBuilder) {
+		m.sanitize = fn
+	}
+}
+
+// WithMeterProvider records the duration of the statements as the
+// db.client.operation.duration histogram.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(m *MiddleWareBuilder) {
+		m.meter = mp
+	}
 }
 
-func New(tracer trace.Tracer) MiddleWareBuilder {
-	return MiddleWareBuilder{tracer: tracer}
+// New creates the middleware, a nil tracer uses the global TracerProvider.
+func New(tracer trace.Tracer, opts ...Option) MiddleWareBuilder {
+	if tracer == nil {
+		tracer = otel.Tracer(instrumentationName)
+	}
+	m := MiddleWareBuilder{
+		tracer: tracer,
+		system: "other_sql",
+	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
 }
+
 func (m *MiddleWareBuilder) Build() middleware.Middleware {
+	var duration metric.Float64Histogram
+	if m.meter != nil {
+		// an instrument that fails to be created is a no-op one
+		duration, _ = m.meter.Meter(instrumentationName).Float64Histogram(
+			"db.client.operation.duration",
+			metric.WithUnit("s"),
+			metric.WithDescription("Duration of database client operations."),
+		)
+	}
 	return func(next middleware.Handler) middleware.Handler {
 		return func(ctx *middleware.Context) *middleware.Result {
+			op := strings.ToUpper(ctx.Kind.String())
+			table := ctx.Table()
+			name := op
+			if table != "" {
+				name = op + " " + table
+			}
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemKey.String(m.system),
+				semconv.DBOperation(op),
+			}
+			if table != "" {
+				attrs = append(attrs, semconv.DBSQLTable(table))
+			}
+
+			orig := ctx.Ctx
 			var span trace.Span
-			ctx.Ctx, span = m.tracer.Start(ctx.Ctx, "sql")
+			ctx.Ctx, span = m.tracer.Start(ctx.Ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...))
+			start := time.Now()
 			res := next(ctx)
-			span.SetAttributes(attribute.String("query:", ctx.Statement))
+			elapsed := time.Since(start)
+			ctx.Ctx = orig
+
+			statement := ctx.Statement
+			if m.sanitize != nil {
+				statement = m.sanitize(statement)
+			}
+			span.SetAttributes(semconv.DBStatement(statement))
+			if res.Err != nil && !errors.Is(res.Err, errs.ErrNoRecord) {
+				span.RecordError(res.Err)
+				span.SetStatus(codes.Error, res.Err.Error())
+				attrs = append(attrs, attribute.String("error.type", errorType(res.Err)))
+			} else if rows, ok := res.Rows(); ok {
+				span.SetAttributes(rowsAffectedKey.Int64(rows))
+			}
 			span.End()
+			if duration != nil {
+				duration.Record(orig, elapsed.Seconds(), metric.WithAttributes(attrs...))
+			}
 			return res
 		}
 	}
 }
+
+// errorType is the type of the innermost error, "_OTHER" for the ones made
+// by errors.New.
+func errorType(err error) string {
+	for {
+		unwrapped := errors.Unwrap(err)
+		if unwrapped == nil {
+			break
+		}
+		err = unwrapped
+	}
+	typ := reflect.TypeOf(err).String()
+	if typ == "*errors.errorString" {
+		return "_OTHER"
+	}
+	return typ
+}
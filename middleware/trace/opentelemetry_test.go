@@ -0,0 +1,168 @@
+package trace
+
+import (
+	"context"
+	"database/sql"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+type rowsAffected int64
+
+func (r rowsAffected) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+func TestMiddleWareBuilder(t *testing.T) {
+	testCases := []struct {
+		name       string
+		opts       []Option
+		ctx        *middleware.Context
+		res        *middleware.Result
+		wantName   string
+		wantAttrs  []attribute.KeyValue
+		wantStatus codes.Code
+		wantEvents int
+	}{
+		{
+			name: "update",
+			opts: []Option{WithDBSystem("mysql")},
+			ctx: &middleware.Context{
+				Kind:      middleware.KindUpdate,
+				Tables:    []string{"user"},
+				Statement: "UPDATE `user` SET `name` = ?;",
+			},
+			res:      &middleware.Result{Res: rowsAffected(2)},
+			wantName: "UPDATE user",
+			wantAttrs: []attribute.KeyValue{
+				attribute.String("db.system", "mysql"),
+				attribute.String("db.operation", "UPDATE"),
+				attribute.String("db.sql.table", "user"),
+				attribute.String("db.statement", "UPDATE `user` SET `name` = ?;"),
+				attribute.Int64("db.rows_affected", 2),
+			},
+			wantStatus: codes.Unset,
+		},
+		{
+			name: "no record",
+			ctx: &middleware.Context{
+				Kind:      middleware.KindSelect,
+				Tables:    []string{"user"},
+				Statement: "SELECT * FROM `user`;",
+			},
+			res:      &middleware.Result{Err: errs.ErrNoRecord},
+			wantName: "SELECT user",
+			wantAttrs: []attribute.KeyValue{
+				attribute.String("db.system", "other_sql"),
+				attribute.String("db.operation", "SELECT"),
+				attribute.String("db.sql.table", "user"),
+				attribute.String("db.statement", "SELECT * FROM `user`;"),
+			},
+			wantStatus: codes.Unset,
+		},
+		{
+			name: "error sanitized",
+			opts: []Option{WithSanitizer(Sanitize)},
+			ctx: &middleware.Context{
+				Kind:      middleware.KindRaw,
+				Statement: "DELETE FROM t1 WHERE name = 'it''s' AND age > 18",
+			},
+			res:      &middleware.Result{Err: sql.ErrConnDone},
+			wantName: "RAW",
+			wantAttrs: []attribute.KeyValue{
+				attribute.String("db.system", "other_sql"),
+				attribute.String("db.operation", "RAW"),
+				attribute.String("db.statement", "DELETE FROM t1 WHERE name = ? AND age > ?"),
+			},
+			wantStatus: codes.Error,
+			wantEvents: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			m := New(tp.Tracer("test"), tc.opts...)
+			tc.ctx.Ctx = context.Background()
+			var inner trace.SpanContext
+			m.Build()(func(ctx *middleware.Context) *middleware.Result {
+				inner = trace.SpanContextFromContext(ctx.Ctx)
+				return tc.res
+			})(tc.ctx)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, tc.wantName, span.Name())
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			assert.ElementsMatch(t, tc.wantAttrs, span.Attributes())
+			assert.Equal(t, tc.wantStatus, span.Status().Code)
+			assert.Len(t, span.Events(), tc.wantEvents)
+			// the handler runs inside the span, the caller gets its context back
+			assert.Equal(t, span.SpanContext(), inner)
+			assert.False(t, trace.SpanContextFromContext(tc.ctx.Ctx).IsValid())
+		})
+	}
+}
+
+func TestMiddleWareBuilder_Metrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	m := New(nil, WithMeterProvider(mp), WithDBSystem("sqlite"))
+	mdl := m.Build()
+	for _, err := range []error{nil, sql.ErrTxDone} {
+		mdl(func(ctx *middleware.Context) *middleware.Result {
+			return &middleware.Result{Err: err}
+		})(&middleware.Context{
+			Ctx:    context.Background(),
+			Kind:   middleware.KindInsert,
+			Tables: []string{"user"},
+		})
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	got := rm.ScopeMetrics[0].Metrics[0]
+	assert.Equal(t, "db.client.operation.duration", got.Name)
+	assert.Equal(t, "s", got.Unit)
+	hist := got.Data.(metricdata.Histogram[float64])
+	require.Len(t, hist.DataPoints, 2)
+	types := make([]string, 0, 2)
+	for _, dp := range hist.DataPoints {
+		assert.Equal(t, uint64(1), dp.Count)
+		table, _ := dp.Attributes.Value("db.sql.table")
+		assert.Equal(t, "user", table.AsString())
+		typ, _ := dp.Attributes.Value("error.type")
+		types = append(types, typ.AsString())
+	}
+	assert.ElementsMatch(t, []string{"", "_OTHER"}, types)
+}
+
+func TestSanitize(t *testing.T) {
+	testCases := []struct {
+		statement string
+		want      string
+	}{
+		{statement: "SELECT * FROM `t1` WHERE `id` = ?;", want: "SELECT * FROM `t1` WHERE `id` = ?;"},
+		{statement: "SELECT * FROM t WHERE id = 12 AND score > 1.5", want: "SELECT * FROM t WHERE id = ? AND score > ?"},
+		{statement: "SELECT * FROM t WHERE name = 'tom' OR name = 'o''neil'", want: "SELECT * FROM t WHERE name = ? OR name = ?"},
+		{statement: "SELECT * FROM t WHERE id = $1", want: "SELECT * FROM t WHERE id = $1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.statement, func(t *testing.T) {
+			assert.Equal(t, tc.want, Sanitize(tc.statement))
+		})
+	}
+}
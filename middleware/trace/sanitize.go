@@ -0,0 +1,50 @@
+package trace
+
+import (
+	"strings"
+)
+
+// Sanitize replaces the string and number literals of statement with "?".
+// Statements made by the builders already bind their values as args, this
+// is meant for raw SQL.
+func Sanitize(statement string) string {
+	var sb strings.Builder
+	sb.Grow(len(statement))
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case c == '\'':
+			// skip to the closing quote, '' being an escaped quote
+			for i++; i < len(statement); i++ {
+				if statement[i] != '\'' {
+					continue
+				}
+				if i+1 < len(statement) && statement[i+1] == '\'' {
+					i++
+					continue
+				}
+				break
+			}
+			sb.WriteByte('?')
+		case isDigit(c) && (i == 0 || !isIdent(statement[i-1])):
+			for i+1 < len(statement) && (isDigit(statement[i+1]) || statement[i+1] == '.') {
+				i++
+			}
+			sb.WriteByte('?')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdent reports whether c may be part of an identifier, so that the
+// digits of `t1` or $1 are left alone.
+func isIdent(c byte) bool {
+	return c == '_' || c == '$' || c == '`' || c == '"' || isDigit(c) ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,127 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestContext_Metadata(t *testing.T) {
+	type TestModel struct {
+		Id   int64
+		Name string
+	}
+	type Course struct {
+		Id        int64
+		StudentId int64
+	}
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	var got *middleware.Context
+	db.Use(func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			ctx.Set("user", "tom")
+			return next(ctx)
+		}
+	}, func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			// the metadata is known before the statement runs
+			snapshot := *ctx
+			got = &snapshot
+			return next(ctx)
+		}
+	})
+
+	testCases := []struct {
+		name       string
+		run        func(ctx *middleware.Context)
+		wantKind   middleware.OpKind
+		wantTables []string
+		wantCols   []string
+	}{
+		{
+			name: "select",
+			run: func(ctx *middleware.Context) {
+				mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				_, _ = NewSelector[TestModel](db).GetMulti(ctx)
+			},
+			wantKind:   middleware.KindSelect,
+			wantTables: []string{"test_model"},
+		},
+		{
+			name: "select join",
+			run: func(ctx *middleware.Context) {
+				mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				_, _ = NewSelector[TestModel](db).
+					From(TableOf(TestModel{}).Join(TableOf(Course{})).
+						Using("Id")).
+					GetMulti(ctx)
+			},
+			wantKind:   middleware.KindSelect,
+			wantTables: []string{"test_model", "course"},
+		},
+		{
+			name: "insert",
+			run: func(ctx *middleware.Context) {
+				mock.ExpectExec("INSERT").WillReturnResult(sqlmock.NewResult(1, 1))
+				NewInsertor[TestModel](db).Values(&TestModel{Id: 1}).Exec(ctx)
+			},
+			wantKind:   middleware.KindInsert,
+			wantTables: []string{"test_model"},
+			wantCols:   []string{"id", "name"},
+		},
+		{
+			name: "upsert",
+			run: func(ctx *middleware.Context) {
+				mock.ExpectExec("INSERT").WillReturnResult(sqlmock.NewResult(1, 1))
+				NewInsertor[TestModel](db).Values(&TestModel{Id: 1}).
+					OnConflict().Update(Assign("Name", "tom")).Exec(ctx)
+			},
+			wantKind:   middleware.KindUpsert,
+			wantTables: []string{"test_model"},
+			wantCols:   []string{"id", "name", "name"},
+		},
+		{
+			name: "update",
+			run: func(ctx *middleware.Context) {
+				mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+				NewUpdater[TestModel](db).Set(Assign("Name", "tom")).Exec(ctx)
+			},
+			wantKind:   middleware.KindUpdate,
+			wantTables: []string{"test_model"},
+			wantCols:   []string{"name"},
+		},
+		{
+			name: "delete",
+			run: func(ctx *middleware.Context) {
+				mock.ExpectExec("DELETE").WillReturnResult(sqlmock.NewResult(0, 1))
+				d := NewDeletor[TestModel](db)
+				d.Where(C("Name").Eq("tom"))
+				d.Exec(ctx)
+			},
+			wantKind:   middleware.KindDelete,
+			wantTables: []string{"test_model"},
+			wantCols:   []string{"name"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got = nil
+			tc.run(&middleware.Context{Ctx: context.Background()})
+			require.NotNil(t, got)
+			assert.Equal(t, tc.wantKind, got.Kind)
+			assert.Equal(t, tc.wantTables, got.Tables)
+			assert.Equal(t, tc.wantCols, got.ArgColumns)
+			assert.Equal(t, "test_model", got.Model.TableName)
+			user, ok := got.Get("user")
+			assert.True(t, ok)
+			assert.Equal(t, "tom", user)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,18 +1,30 @@
 package model
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/utils"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Model struct {
+	// Type is the struct type of the model
+	Type      reflect.Type
 	TableName string
 	Fields    []*FieldInfo
 	GoMap     map[string]*FieldInfo
 	ColMap    map[string]*FieldInfo
+	// Tenant is the field tagged with orm:"tenant", nil when the model is
+	// not scoped by tenant
+	Tenant *FieldInfo
+	// PK is the field tagged with orm:"pk", or the id column when none is,
+	// nil when the model has neither
+	PK *FieldInfo
 }
 type TableName interface {
 	TableName() string
@@ -24,23 +36,102 @@ type FieldInfo struct {
 	ColName string
 	GoName  string
 	Type    reflect.Type
-	Offset  uintptr
+	// Offset is the offset of the field in the model struct, meaningless
+	// when Indirect
+	Offset uintptr
+	// Index is the index sequence of the field, going through the embedded
+	// structs, see Value and Settable
+	Index []int
+	// Indirect tells whether the field is reached through an embedded
+	// pointer, which may be nil
+	Indirect bool
 }
+
+// Value returns the field of val, a model struct, reading the fields behind
+// a nil embedded pointer as zero values.
+func (f *FieldInfo) Value(val reflect.Value) reflect.Value {
+	fv, err := val.FieldByIndexErr(f.Index)
+	if err != nil {
+		return reflect.Zero(f.Type)
+	}
+	if !fv.CanInterface() && fv.CanAddr() {
+		fv = exported(fv)
+	}
+	return fv
+}
+
+// Settable returns the field of val, an addressable model struct, allocating
+// the nil embedded pointers on the way. Unexported fields are settable too.
+func (f *FieldInfo) Settable(val reflect.Value) reflect.Value {
+	for i, idx := range f.Index {
+		if i > 0 && val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = exported(val.Field(idx))
+	}
+	return val
+}
+
+// Detach replaces the embedded pointers the field is reached through in val,
+// an addressable copy of a model struct, by pointers to copies: setting the
+// field of val then leaves the original untouched.
+func (f *FieldInfo) Detach(val reflect.Value) {
+	for _, idx := range f.Index[:len(f.Index)-1] {
+		val = exported(val.Field(idx))
+		if val.Kind() != reflect.Pointer {
+			continue
+		}
+		if val.IsNil() {
+			return
+		}
+		cp := reflect.New(val.Type().Elem())
+		cp.Elem().Set(val.Elem())
+		val.Set(cp)
+		val = cp.Elem()
+	}
+}
+
+// exported returns v, an addressable value, without the read-only flag of the
+// unexported fields.
+func exported(v reflect.Value) reflect.Value {
+	if v.CanSet() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), v.Addr().UnsafePointer()).Elem()
+}
+
 type Registry struct {
 	models sync.Map
+	// scopes holds the []Scope of a type, replaced on every registration
+	scopes sync.Map
+	mu     sync.Mutex
+}
+
+// Scope is a default scope of a model. Its Value is opaque to the registry.
+type Scope struct {
+	Name  string
+	Value any
 }
 
 const (
 	columnTag = "column"
+	tenantTag = "tenant"
+	pkTag     = "pk"
 )
 
+// flagTags are the tags given without a value.
+var flagTags = map[string]struct{}{
+	tenantTag: {},
+	pkTag:     {},
+}
+
 func (r *Registry) Get(entity any) (*Model, error) {
-	typ := reflect.TypeOf(entity)
-	for typ.Kind() == reflect.Pointer {
-		typ = typ.Elem()
-	}
-	if typ.Kind() != reflect.Struct {
-		return nil, errs.ErrInvalidModel
+	typ, err := structOf(entity)
+	if err != nil {
+		return nil, err
 	}
 	m, ok := r.models.Load(typ)
 	if !ok {
@@ -53,29 +144,66 @@ func (r *Registry) Get(entity any) (*Model, error) {
 	}
 	return m.(*Model), nil
 }
-func (r *Registry) parseModel(typ reflect.Type) (*Model, error) {
-	numField := typ.NumField()
-	fields := make([]*FieldInfo, 0, numField)
-	goMap := make(map[string]*FieldInfo, numField)
-	colMap := make(map[string]*FieldInfo, numField)
-	for i := 0; i < numField; i++ {
-		tags, err := r.parseTag(typ.Field(i).Tag)
-		if err != nil {
-			return nil, err
-		}
-		colName, ok := tags["column"]
-		if !ok || colName == "" {
-			colName = utils.CamelToSnake(typ.Field(i).Name)
-		}
-		fi := &FieldInfo{
-			ColName: colName,
-			GoName:  typ.Field(i).Name,
-			Type:    typ.Field(i).Type,
-			Offset:  typ.Field(i).Offset,
+
+// RegisterScope adds a default scope to the model of entity, replacing the
+// one with the same name.
+func (r *Registry) RegisterScope(entity any, name string, value any) error {
+	typ, err := structOf(entity)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var scopes []Scope
+	if old, ok := r.scopes.Load(typ); ok {
+		scopes = make([]Scope, 0, len(old.([]Scope))+1)
+		for _, sc := range old.([]Scope) {
+			if sc.Name != name {
+				scopes = append(scopes, sc)
+			}
 		}
-		goMap[typ.Field(i).Name] = fi
-		colMap[colName] = fi
-		fields = append(fields, fi)
+	}
+	r.scopes.Store(typ, append(scopes, Scope{Name: name, Value: value}))
+	return nil
+}
+
+// Scopes returns the default scopes of the model of entity, in registration
+// order.
+func (r *Registry) Scopes(entity any) []Scope {
+	typ, err := structOf(entity)
+	if err != nil {
+		return nil
+	}
+	scopes, ok := r.scopes.Load(typ)
+	if !ok {
+		return nil
+	}
+	return scopes.([]Scope)
+}
+
+func structOf(entity any) (reflect.Type, error) {
+	typ := reflect.TypeOf(entity)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, errs.ErrInvalidModel
+	}
+	return typ, nil
+}
+
+func (r *Registry) parseModel(typ reflect.Type) (*Model, error) {
+	p := &modelParser{
+		fields: make([]*FieldInfo, 0, typ.NumField()),
+		goMap:  make(map[string]*FieldInfo, typ.NumField()),
+		colMap: make(map[string]*FieldInfo, typ.NumField()),
+	}
+	if err := p.parse(typ, nil, 0, false); err != nil {
+		return nil, err
+	}
+	pk := p.pk
+	if pk == nil {
+		pk = p.colMap["id"]
 	}
 	var tableName string
 	if reflect.PointerTo(typ).Implements(tableNameType) {
@@ -84,14 +212,104 @@ func (r *Registry) parseModel(typ reflect.Type) (*Model, error) {
 		tableName = utils.CamelToSnake(typ.Name())
 	}
 	return &Model{
+		Type:      typ,
 		TableName: tableName,
-		GoMap:     goMap,
-		ColMap:    colMap,
-		Fields:    fields,
+		GoMap:     p.goMap,
+		ColMap:    p.colMap,
+		Fields:    p.fields,
+		Tenant:    p.tenant,
+		PK:        pk,
 	}, nil
 }
 
-func (r *Registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
+type modelParser struct {
+	fields     []*FieldInfo
+	goMap      map[string]*FieldInfo
+	colMap     map[string]*FieldInfo
+	tenant, pk *FieldInfo
+}
+
+// parse adds the fields of typ, found at index from the model struct. The
+// fields of the embedded structs are added in place of them, as Go promotes
+// them, a name or a column defined twice is an error.
+func (p *modelParser) parse(typ reflect.Type, index []int, offset uintptr, indirect bool) error {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tags, err := ParseTag(sf.Tag)
+		if err != nil {
+			return err
+		}
+		if embedded, ptr := embeddedStruct(sf); embedded && len(tags) == 0 {
+			et := sf.Type
+			if ptr {
+				et = et.Elem()
+			}
+			if err = p.parse(et, append(slices.Clone(index), i), offset+sf.Offset, indirect || ptr); err != nil {
+				return err
+			}
+			continue
+		}
+		colName := ColumnOf(sf.Name, tags)
+		fi := &FieldInfo{
+			ColName:  colName,
+			GoName:   sf.Name,
+			Type:     sf.Type,
+			Offset:   offset + sf.Offset,
+			Index:    append(slices.Clone(index), i),
+			Indirect: indirect,
+		}
+		if _, ok := tags[tenantTag]; ok {
+			if p.tenant != nil {
+				return errs.ErrInvalidTags
+			}
+			p.tenant = fi
+		}
+		if _, ok := tags[pkTag]; ok {
+			if p.pk != nil {
+				return errs.ErrInvalidTags
+			}
+			p.pk = fi
+		}
+		if _, ok := p.goMap[fi.GoName]; ok {
+			return errs.ErrInvalidModel
+		}
+		if _, ok := p.colMap[colName]; ok {
+			return errs.ErrInvalidModel
+		}
+		p.goMap[fi.GoName] = fi
+		p.colMap[colName] = fi
+		p.fields = append(p.fields, fi)
+	}
+	return nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// embeddedStruct tells whether sf is an embedded struct, or pointer to
+// struct, whose fields are columns. Embedded scanners, valuers and times are
+// columns themselves.
+func embeddedStruct(sf reflect.StructField) (embedded, ptr bool) {
+	if !sf.Anonymous {
+		return false, false
+	}
+	typ := sf.Type
+	if typ.Kind() == reflect.Pointer {
+		typ, ptr = typ.Elem(), true
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType ||
+		reflect.PointerTo(typ).Implements(scannerType) || typ.Implements(valuerType) {
+		return false, false
+	}
+	return true, ptr
+}
+
+// ParseTag returns the settings of the orm tag of a field, flags such as
+// tenant mapped to "".
+func ParseTag(tag reflect.StructTag) (map[string]string, error) {
 	res := make(map[string]string, 4)
 	fullTag, ok := tag.Lookup("orm")
 	if !ok {
@@ -101,9 +319,22 @@ func (r *Registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 	for _, pair := range pairs {
 		seg := strings.SplitN(pair, "=", 2)
 		if len(seg) != 2 {
-			return nil, errs.ErrInvalidTags
+			if _, ok = flagTags[seg[0]]; !ok {
+				return nil, errs.ErrInvalidTags
+			}
+			res[seg[0]] = ""
+			continue
 		}
 		res[seg[0]] = seg[1]
 	}
 	return res, nil
 }
+
+// ColumnOf returns the column of the field name, given the settings of its
+// tag: the column setting or name in snake case.
+func ColumnOf(name string, tags map[string]string) string {
+	if col := tags[columnTag]; col != "" {
+		return col
+	}
+	return utils.CamelToSnake(name)
+}
@@ -0,0 +1,53 @@
+package go_orm
+
+import (
+	"database/sql"
+	"github.com/kisara71/go-orm/errs"
+)
+
+// ModelAccessor is implemented by models whose accessors were generated by
+// go-orm-accessor. Selector and Insertor prefer it over UnsafeAccessor because
+// it reads and writes fields without reflection.
+type ModelAccessor interface {
+	// Scan returns pointers to the fields backing cols, in order, ready to be
+	// passed to sql.Rows.Scan.
+	Scan(cols []string) ([]any, error)
+	// Values returns the values of the Go fields named by fields, in order.
+	Values(fields []string) ([]any, error)
+}
+
+// scanRow scans the current row into entity, using the generated accessor
+// when entity has one and uac otherwise.
+func scanRow(uac UnsafeAccessor, entity any, rows *sql.Rows, cols []string) error {
+	ma, ok := entity.(ModelAccessor)
+	if !ok {
+		uac.Access(entity)
+		return uac.Set(rows)
+	}
+	dest, err := ma.Scan(cols)
+	if err != nil {
+		return err
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return errs.ErrScanFailed
+	}
+	return nil
+}
+
+// fieldValues returns the values of fields on entity, using the generated
+// accessor when entity has one and uac otherwise.
+func fieldValues(uac UnsafeAccessor, entity any, fields []string) ([]any, error) {
+	if ma, ok := entity.(ModelAccessor); ok {
+		return ma.Values(fields)
+	}
+	uac.Access(entity)
+	vals := make([]any, 0, len(fields))
+	for _, field := range fields {
+		val, err := uac.Fetch(field)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
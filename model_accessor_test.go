@@ -0,0 +1,168 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+//go:generate go run ./cmd/go-orm-accessor -type accessorModel -out model_accessor_gen_test.go model_accessor_test.go
+
+type plainModel struct {
+	ID      int64 `orm:"column=id"`
+	Name    string
+	Age     int
+	Address sql.NullString
+}
+
+type accessorModel struct {
+	ID      int64 `orm:"column=id"`
+	Name    string
+	Age     int
+	Address sql.NullString
+}
+
+func TestModelAccessor_GetMulti(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	testCases := []struct {
+		name    string
+		expect  func(mk sqlmock.Sqlmock)
+		wantRes []*accessorModel
+		wantErr error
+	}{
+		{
+			name: "scan",
+			expect: func(mk sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address"}).
+					AddRow(1, "wang", 18, "addr1").
+					AddRow(2, "li", 20, nil)
+				mk.ExpectQuery("SELECT \\* FROM `accessor_model`;").WillReturnRows(rows)
+			},
+			wantRes: []*accessorModel{
+				{ID: 1, Name: "wang", Age: 18, Address: sql.NullString{String: "addr1", Valid: true}},
+				{ID: 2, Name: "li", Age: 20},
+			},
+		},
+		{
+			name: "unknown column",
+			expect: func(mk sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "unknown_col"}).AddRow(1, "x")
+				mk.ExpectQuery("SELECT \\* FROM `accessor_model`;").WillReturnRows(rows)
+			},
+			wantErr: errs.ErrUnknownColumn,
+		},
+		{
+			name: "scan error",
+			expect: func(mk sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).AddRow("not_int")
+				mk.ExpectQuery("SELECT \\* FROM `accessor_model`;").WillReturnRows(rows)
+			},
+			wantErr: errs.ErrScanFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.expect(mock)
+			ctx := &middleware.Context{Ctx: context.Background()}
+			res, err := NewSelector[accessorModel](db).GetMulti(ctx)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestModelAccessor_Insert(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	q, err := NewInsertor[accessorModel](db).Columns("ID", "Name").
+		Values(&accessorModel{ID: 1, Name: "wang"}, &accessorModel{ID: 2, Name: "li"}).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "INSERT INTO `accessor_model` (`id`, `name`) VALUES (?, ?), (?, ?);",
+		Args: []any{int64(1), "wang", int64(2), "li"},
+	}, q)
+}
+
+func openBenchDB(b *testing.B, rows int) *DB {
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(b, err)
+	sqldb.SetMaxOpenConns(1)
+	b.Cleanup(func() { _ = sqldb.Close() })
+	for _, table := range []string{"plain_model", "accessor_model"} {
+		_, err = sqldb.Exec(fmt.Sprintf(
+			`CREATE TABLE %s (id INTEGER PRIMARY KEY, name TEXT, age INTEGER, address TEXT)`, table))
+		require.NoError(b, err)
+		for i := 0; i < rows; i++ {
+			_, err = sqldb.Exec(fmt.Sprintf(
+				`INSERT INTO %s (id, name, age, address) VALUES (?, ?, ?, ?)`, table), i, "name", i, "address")
+			require.NoError(b, err)
+		}
+	}
+	return OpenDB(sqldb, WithDialect(SqliteDialect))
+}
+
+func BenchmarkSelector_GetMulti(b *testing.B) {
+	db := openBenchDB(b, 100)
+	b.Run("unsafe accessor", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := NewSelector[plainModel](db).GetMulti(&middleware.Context{Ctx: context.Background()})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("generated accessor", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := NewSelector[accessorModel](db).GetMulti(&middleware.Context{Ctx: context.Background()})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkInsertor_Build(b *testing.B) {
+	db := openBenchDB(b, 0)
+	plain := make([]*plainModel, 1000)
+	generated := make([]*accessorModel, 1000)
+	for i := range plain {
+		plain[i] = &plainModel{ID: int64(i), Name: "name", Age: i}
+		generated[i] = &accessorModel{ID: int64(i), Name: "name", Age: i}
+	}
+	b.Run("unsafe accessor", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := NewInsertor[plainModel](db).Values(plain...).Build()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("generated accessor", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := NewInsertor[accessorModel](db).Values(generated...).Build()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,396 @@
+package ormtest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+// recordConnector opens the connections of the recorded database through
+// the driver of db.
+type recordConnector struct {
+	tape *tape
+	db   *sql.DB
+	dsn  string
+}
+
+func (c *recordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var (
+		conn driver.Conn
+		err  error
+	)
+	if dc, ok := c.db.Driver().(driver.DriverContext); ok {
+		var connector driver.Connector
+		connector, err = dc.OpenConnector(c.dsn)
+		if err != nil {
+			return nil, err
+		}
+		conn, err = connector.Connect(ctx)
+	} else {
+		conn, err = c.db.Driver().Open(c.dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &recordConn{Conn: conn, tape: c.tape}, nil
+}
+
+func (c *recordConnector) Driver() driver.Driver {
+	return c.db.Driver()
+}
+
+type recordConn struct {
+	driver.Conn
+	tape *tape
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *recordConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &recordStmt{Stmt: stmt, query: query, tape: c.tape}, nil
+}
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *recordConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = bt.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	c.tape.add(entry{Op: opBegin, Err: errString(err)})
+	if err != nil {
+		return nil, err
+	}
+	return &recordTx{Tx: tx, tape: c.tape}, nil
+}
+
+// ExecContext lets database/sql prepare the statement when the driver
+// cannot execute it directly.
+func (c *recordConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ex, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	res, err := ex.ExecContext(ctx, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		return nil, err
+	}
+	return recordExec(c.tape, query, args, res, err)
+}
+
+func (c *recordConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := q.QueryContext(ctx, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		return nil, err
+	}
+	return recordQuery(c.tape, query, args, rows, err)
+}
+
+type recordStmt struct {
+	driver.Stmt
+	query string
+	tape  *tape
+}
+
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *recordStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var (
+		res driver.Result
+		err error
+	)
+	if ex, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = ex.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(plain(args))
+	}
+	return recordExec(s.tape, s.query, args, res, err)
+}
+
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *recordStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(plain(args))
+	}
+	return recordQuery(s.tape, s.query, args, rows, err)
+}
+
+type recordTx struct {
+	driver.Tx
+	tape *tape
+}
+
+func (t *recordTx) Commit() error {
+	err := t.Tx.Commit()
+	t.tape.add(entry{Op: opCommit, Err: errString(err)})
+	return err
+}
+
+func (t *recordTx) Rollback() error {
+	err := t.Tx.Rollback()
+	t.tape.add(entry{Op: opRollback, Err: errString(err)})
+	return err
+}
+
+func recordExec(tp *tape, query string, args []driver.NamedValue, res driver.Result, err error) (driver.Result, error) {
+	e := entry{Op: opExec, SQL: query, Args: valuesOf(args), Err: errString(err)}
+	if err == nil {
+		// drivers without them, e.g. LastInsertId on PostgreSQL, replay 0
+		e.LastInsertId, _ = res.LastInsertId()
+		e.RowsAffected, _ = res.RowsAffected()
+	}
+	tp.add(e)
+	return res, err
+}
+
+// recordQuery reads every row, which are then served from the entry.
+func recordQuery(tp *tape, query string, args []driver.NamedValue, rs driver.Rows, err error) (driver.Rows, error) {
+	e := entry{Op: opQuery, SQL: query, Args: valuesOf(args), Err: errString(err)}
+	if err != nil {
+		tp.add(e)
+		return nil, err
+	}
+	defer rs.Close()
+	e.Columns = rs.Columns()
+	dest := make([]driver.Value, len(e.Columns))
+	for {
+		err = rs.Next(dest)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			e.Rows, e.Err = nil, err.Error()
+			tp.add(e)
+			return nil, err
+		}
+		row := make([]value, len(dest))
+		for i, v := range dest {
+			// drivers may reuse the buffer on the next row
+			if b, ok := v.([]byte); ok {
+				v = bytes.Clone(b)
+			}
+			row[i] = value{v: v}
+		}
+		e.Rows = append(e.Rows, row)
+	}
+	tp.add(e)
+	return &rows{e: e}, nil
+}
+
+// replayConnector serves the statements from the tape.
+type replayConnector struct {
+	tape *tape
+}
+
+func (c *replayConnector) Connect(context.Context) (driver.Conn, error) {
+	return &replayConn{tape: c.tape}, nil
+}
+
+func (c *replayConnector) Driver() driver.Driver {
+	return replayDriver{c: c}
+}
+
+type replayDriver struct {
+	c *replayConnector
+}
+
+func (d replayDriver) Open(string) (driver.Conn, error) {
+	return d.c.Connect(context.Background())
+}
+
+type replayConn struct {
+	tape *tape
+}
+
+func (c *replayConn) Prepare(query string) (driver.Stmt, error) {
+	return &replayStmt{conn: c, query: query}, nil
+}
+
+func (c *replayConn) Close() error {
+	return nil
+}
+
+func (c *replayConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *replayConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	e, err := c.tape.next(entry{Op: opBegin})
+	if err != nil {
+		return nil, err
+	}
+	if err = e.err(); err != nil {
+		return nil, err
+	}
+	return replayTx{tape: c.tape}, nil
+}
+
+func (c *replayConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.tape.next(entry{Op: opExec, SQL: query, Args: valuesOf(args)})
+	if err != nil {
+		return nil, err
+	}
+	if err = e.err(); err != nil {
+		return nil, err
+	}
+	return result{e: e}, nil
+}
+
+func (c *replayConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.tape.next(entry{Op: opQuery, SQL: query, Args: valuesOf(args)})
+	if err != nil {
+		return nil, err
+	}
+	if err = e.err(); err != nil {
+		return nil, err
+	}
+	return &rows{e: e}, nil
+}
+
+type replayStmt struct {
+	conn  *replayConn
+	query string
+}
+
+func (s *replayStmt) Close() error {
+	return nil
+}
+
+func (s *replayStmt) NumInput() int {
+	return -1
+}
+
+func (s *replayStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *replayStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *replayStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func (s *replayStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+type replayTx struct {
+	tape *tape
+}
+
+func (t replayTx) Commit() error {
+	return t.end(opCommit)
+}
+
+func (t replayTx) Rollback() error {
+	return t.end(opRollback)
+}
+
+func (t replayTx) end(op string) error {
+	e, err := t.tape.next(entry{Op: op})
+	if err != nil {
+		return err
+	}
+	return e.err()
+}
+
+type result struct {
+	e entry
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.e.LastInsertId, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.e.RowsAffected, nil
+}
+
+// rows serves the rows of a query entry.
+type rows struct {
+	e   entry
+	pos int
+}
+
+func (r *rows) Columns() []string {
+	return r.e.Columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.e.Rows) {
+		return io.EOF
+	}
+	for i, v := range r.e.Rows[r.pos] {
+		dest[i] = v.v
+	}
+	r.pos++
+	return nil
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	res := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		res = append(res, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+	return res
+}
+
+func plain(args []driver.NamedValue) []driver.Value {
+	res := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		res = append(res, arg.Value)
+	}
+	return res
+}
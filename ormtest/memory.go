@@ -0,0 +1,245 @@
+package ormtest
+
+import (
+	"context"
+	"database/sql"
+	orm "github.com/kisara71/go-orm"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"maps"
+	"reflect"
+	"sync"
+)
+
+var _ orm.TxEvaluator = &Memory{}
+
+// Memory is an orm.Evaluator keeping the rows of every model in memory, so
+// that repository tests run without any database:
+//
+//	db := orm.OpenEvaluator(ormtest.NewMemory())
+//
+// Integer primary keys left to zero are generated on insert, a duplicate
+// primary key fails with errs.ErrDuplicateKey.
+type Memory struct {
+	mu     sync.Mutex
+	tables map[string]*memTable
+}
+
+// memTable holds the rows of a model, a *T each, in insertion order.
+type memTable struct {
+	rows   []any
+	lastID int64
+	// keys holds the primary keys of the rows, see keyOf
+	keys map[any]struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tables: make(map[string]*memTable, 8),
+	}
+}
+
+func (m *Memory) Query(_ context.Context, st *orm.Statement) ([]any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return query(m.tables, st)
+}
+
+func (m *Memory) Exec(_ context.Context, st *orm.Statement) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return exec(m.tables, st)
+}
+
+// Begin starts a transaction working on a copy of the rows, which replaces
+// them on commit. Transactions are not isolated from each other, the last
+// one to commit wins.
+func (m *Memory) Begin(context.Context) (orm.EvaluatorTx, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &memTx{m: m, tables: clone(m.tables)}, nil
+}
+
+type memTx struct {
+	mu     sync.Mutex
+	m      *Memory
+	tables map[string]*memTable
+	done   bool
+}
+
+func (tx *memTx) Query(_ context.Context, st *orm.Statement) ([]any, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, sql.ErrTxDone
+	}
+	return query(tx.tables, st)
+}
+
+func (tx *memTx) Exec(_ context.Context, st *orm.Statement) (sql.Result, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, sql.ErrTxDone
+	}
+	return exec(tx.tables, st)
+}
+
+func (tx *memTx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	tx.m.mu.Lock()
+	defer tx.m.mu.Unlock()
+	tx.m.tables = tx.tables
+	return nil
+}
+
+func (tx *memTx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	return nil
+}
+
+func query(tables map[string]*memTable, st *orm.Statement) ([]any, error) {
+	tbl, ok := tables[st.Model.TableName]
+	if !ok {
+		return nil, nil
+	}
+	res := make([]any, 0, len(tbl.rows))
+	for _, row := range tbl.rows {
+		ok, err := st.Match(row)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, copyRow(row))
+		}
+	}
+	if err := st.Sort(res); err != nil {
+		return nil, err
+	}
+	if st.Offset > 0 {
+		res = res[min(st.Offset, int64(len(res))):]
+	}
+	if st.Limit > 0 {
+		res = res[:min(st.Limit, int64(len(res)))]
+	}
+	return res, nil
+}
+
+func exec(tables map[string]*memTable, st *orm.Statement) (sql.Result, error) {
+	tbl, ok := tables[st.Model.TableName]
+	if !ok {
+		tbl = &memTable{keys: make(map[any]struct{}, 8)}
+		tables[st.Model.TableName] = tbl
+	}
+	switch st.Kind {
+	case middleware.KindInsert:
+		return insert(tbl, st)
+	case middleware.KindUpdate, middleware.KindDelete:
+		kept := tbl.rows[:0:0]
+		var affected int64
+		for _, row := range tbl.rows {
+			ok, err := st.Match(row)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				kept = append(kept, row)
+				continue
+			}
+			affected++
+			if st.Kind == middleware.KindUpdate {
+				// rows may be shared with a committed snapshot, never
+				// modify them in place
+				row = copyRow(row)
+				if err = st.Apply(row); err != nil {
+					return nil, err
+				}
+				kept = append(kept, row)
+			}
+		}
+		tbl.rows = kept
+		if pk := st.Model.PK; pk != nil && affected > 0 {
+			// an UPDATE may change the keys
+			tbl.keys = make(map[any]struct{}, len(kept))
+			for _, row := range kept {
+				tbl.keys[keyOf(pk.Value(reflect.ValueOf(row).Elem()))] = struct{}{}
+			}
+		}
+		return result{e: entry{RowsAffected: affected}}, nil
+	}
+	return nil, errs.ErrUnsupported
+}
+
+func insert(tbl *memTable, st *orm.Statement) (sql.Result, error) {
+	pk := st.Model.PK
+	rows := make([]any, 0, len(st.Values))
+	keys := make(map[any]struct{}, len(st.Values))
+	var lastID int64
+	for _, val := range st.Values {
+		src := reflect.ValueOf(val).Elem()
+		row := reflect.New(src.Type())
+		for _, fd := range st.Columns {
+			fd.Settable(row.Elem()).Set(fd.Value(src))
+		}
+		if pk != nil {
+			id := pk.Settable(row.Elem())
+			if id.CanInt() && id.IsZero() {
+				id.SetInt(tbl.lastID + 1)
+			}
+			if id.CanInt() {
+				tbl.lastID = max(tbl.lastID, id.Int())
+				lastID = id.Int()
+			}
+			key := keyOf(id)
+			_, dup := tbl.keys[key]
+			if _, ok := keys[key]; ok || dup {
+				return nil, errs.ErrDuplicateKey
+			}
+			keys[key] = struct{}{}
+		}
+		rows = append(rows, row.Interface())
+	}
+	tbl.rows = append(tbl.rows, rows...)
+	maps.Copy(tbl.keys, keys)
+	return result{e: entry{LastInsertId: lastID, RowsAffected: int64(len(rows))}}, nil
+}
+
+// clone copies the tables, the rows themselves are shared as they are
+// never modified in place.
+func clone(tables map[string]*memTable) map[string]*memTable {
+	res := make(map[string]*memTable, len(tables))
+	for name, tbl := range tables {
+		res[name] = &memTable{
+			rows:   append([]any(nil), tbl.rows...),
+			lastID: tbl.lastID,
+			keys:   maps.Clone(tbl.keys),
+		}
+	}
+	return res
+}
+
+// keyOf returns the map key of a primary key, byte slices are keyed by
+// their content.
+func keyOf(pk reflect.Value) any {
+	if pk.Kind() == reflect.Slice && pk.Type().Elem().Kind() == reflect.Uint8 {
+		return string(pk.Bytes())
+	}
+	return pk.Interface()
+}
+
+func copyRow(row any) any {
+	src := reflect.ValueOf(row).Elem()
+	dst := reflect.New(src.Type())
+	dst.Elem().Set(src)
+	return dst.Interface()
+}
@@ -0,0 +1,232 @@
+package ormtest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	orm "github.com/kisara71/go-orm"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/ormtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type Book struct {
+	Id     int64
+	Title  string
+	Price  float64
+	Author sql.NullString
+	Stock  *int
+}
+
+func seedBooks(t *testing.T) *orm.DB {
+	db := orm.OpenEvaluator(ormtest.NewMemory())
+	res := orm.NewInsertor[Book](db).Values(
+		&Book{Title: "go", Price: 30, Author: sql.NullString{String: "rob", Valid: true}},
+		&Book{Title: "sql", Price: 20},
+		&Book{Id: 10, Title: "orm", Price: 40, Author: sql.NullString{String: "ken", Valid: true}},
+		&Book{Title: "tcp", Price: 20, Author: sql.NullString{String: "rob", Valid: true}},
+	).Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+	id, err := res.LastInsertID()
+	require.NoError(t, err)
+	require.Equal(t, int64(11), id)
+	return db
+}
+
+func titles(books []*Book) []string {
+	res := make([]string, 0, len(books))
+	for _, b := range books {
+		res = append(res, b.Title)
+	}
+	return res
+}
+
+func TestMemory_Select(t *testing.T) {
+	db := seedBooks(t)
+	testCases := []struct {
+		name    string
+		s       *orm.Selector[Book]
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "all",
+			s:    orm.NewSelector[Book](db),
+			want: []string{"go", "sql", "orm", "tcp"},
+		},
+		{
+			name: "eq",
+			s:    orm.NewSelector[Book](db).Where(orm.C("Id").Eq(2)),
+			want: []string{"sql"},
+		},
+		{
+			name: "and or not",
+			s: orm.NewSelector[Book](db).Where(
+				orm.C("Price").LT(35).And(orm.Not(orm.C("Title").Eq("go"))).Or(orm.C("Id").GT(10))),
+			want: []string{"sql", "tcp"},
+		},
+		{
+			name: "null never matches",
+			s:    orm.NewSelector[Book](db).Where(orm.Not(orm.C("Author").Eq("rob"))),
+			want: []string{"orm"},
+		},
+		{
+			name: "order limit offset",
+			s: orm.NewSelector[Book](db).OrderBy(orm.ASC("Price"), orm.DESC("Id")).
+				Limit(2).Offset(1),
+			want: []string{"sql", "go"},
+		},
+		{
+			name: "order null first",
+			s:    orm.NewSelector[Book](db).OrderBy(orm.ASC("Author"), orm.ASC("Id")),
+			want: []string{"sql", "orm", "go", "tcp"},
+		},
+		{
+			name: "select star",
+			s:    orm.NewSelector[Book](db).Select(orm.C("*")).Where(orm.C("Id").Eq(10)),
+			want: []string{"orm"},
+		},
+		{
+			name:    "select aggregate",
+			s:       orm.NewSelector[Book](db).Select(orm.Count("Id")),
+			wantErr: errs.ErrUnsupported,
+		},
+		{
+			name:    "unknown column",
+			s:       orm.NewSelector[Book](db).Where(orm.C("Id").Eq(orm.C("Missing"))),
+			wantErr: errs.ErrUnknownField,
+		},
+		{
+			name:    "raw",
+			s:       orm.NewSelector[Book](db).Where(orm.Raw("price > ?", 1).AsPredicate()),
+			wantErr: errs.ErrUnsupported,
+		},
+		{
+			name: "join",
+			s: orm.NewSelector[Book](db).
+				From(orm.TableOf(Book{}).Join(orm.TableOf(Book{})).Using("Id")),
+			wantErr: errs.ErrUnsupported,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.s.GetMulti(&middleware.Context{Ctx: context.Background()})
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, titles(res))
+		})
+	}
+}
+
+func TestMemory_Get(t *testing.T) {
+	db := seedBooks(t)
+	ctx := &middleware.Context{Ctx: context.Background()}
+	book, err := orm.NewSelector[Book](db).Select(orm.C("Title")).
+		Where(orm.C("Id").Eq(10)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &Book{Title: "orm"}, book)
+
+	// the rows handed out are copies
+	book.Title = "changed"
+	book, err = orm.NewSelector[Book](db).Where(orm.C("Id").Eq(10)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "orm", book.Title)
+
+	_, err = orm.NewSelector[Book](db).Where(orm.C("Id").Eq(99)).Get(ctx)
+	assert.Equal(t, errs.ErrNoRecord, err)
+}
+
+func TestMemory_Exec(t *testing.T) {
+	db := seedBooks(t)
+	ctx := &middleware.Context{Ctx: context.Background()}
+	stock := 3
+
+	res := orm.NewUpdater[Book](db).Set(orm.Assign("Price", 25), orm.Assign("Stock", &stock)).
+		Where(orm.C("Price").Eq(20)).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	res = orm.NewUpdater[Book](db).FromStruct(&Book{Author: sql.NullString{String: "ann", Valid: true}}).
+		Where(orm.C("Id").Eq(2)).Exec(ctx)
+	require.NoError(t, res.Err())
+
+	d := orm.NewDeletor[Book](db)
+	d.Where(orm.C("Title").Eq("go"))
+	require.NoError(t, d.Exec(ctx).Err())
+
+	books, err := orm.NewSelector[Book](db).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*Book{
+		{Id: 2, Title: "sql", Price: 25, Author: sql.NullString{String: "ann", Valid: true}, Stock: &stock},
+		{Id: 10, Title: "orm", Price: 40, Author: sql.NullString{String: "ken", Valid: true}},
+		{Id: 11, Title: "tcp", Price: 25, Author: sql.NullString{String: "rob", Valid: true}, Stock: &stock},
+	}, books)
+
+	res = orm.NewInsertor[Book](db).Values(&Book{Id: 2}).Exec(ctx)
+	assert.Equal(t, errs.ErrDuplicateKey, res.Err())
+	res = orm.NewInsertor[Book](db).Values(&Book{Id: 30}, &Book{Id: 30}).Exec(ctx)
+	assert.Equal(t, errs.ErrDuplicateKey, res.Err())
+	// the keys of deleted and updated rows are free again
+	require.NoError(t, orm.NewUpdater[Book](db).Set(orm.Assign("Id", 12)).
+		Where(orm.C("Id").Eq(10)).Exec(ctx).Err())
+	require.NoError(t, orm.NewInsertor[Book](db).Values(&Book{Id: 1}, &Book{Id: 10}).Exec(ctx).Err())
+	res = orm.NewInsertor[Book](db).Values(&Book{Id: 12}).Exec(ctx)
+	assert.Equal(t, errs.ErrDuplicateKey, res.Err())
+	res = orm.NewInsertor[Book](db).Values(&Book{Id: 20}).OnConflict().Update(orm.C("Title")).Exec(ctx)
+	assert.Equal(t, errs.ErrUnsupported, res.Err())
+}
+
+func TestMemory_Tx(t *testing.T) {
+	db := seedBooks(t)
+	errFn := errors.New("fn failed")
+	update := func(title string, fnErr error) error {
+		return db.DoTx(context.Background(), func(ctx context.Context, tx *orm.Transaction) error {
+			res := orm.NewUpdater[Book](tx).Set(orm.Assign("Title", title)).
+				Where(orm.C("Id").Eq(1)).Exec(&middleware.Context{Ctx: ctx})
+			require.NoError(t, res.Err())
+			// the transaction sees its own changes
+			book, err := orm.NewSelector[Book](tx).Where(orm.C("Id").Eq(1)).
+				Get(&middleware.Context{Ctx: ctx})
+			require.NoError(t, err)
+			assert.Equal(t, title, book.Title)
+			return fnErr
+		})
+	}
+	get := func() string {
+		book, err := orm.NewSelector[Book](db).Where(orm.C("Id").Eq(1)).
+			Get(&middleware.Context{Ctx: context.Background()})
+		require.NoError(t, err)
+		return book.Title
+	}
+
+	assert.Equal(t, errFn, update("rolled back", errFn))
+	assert.Equal(t, "go", get())
+	require.NoError(t, update("committed", nil))
+	assert.Equal(t, "committed", get())
+}
+
+type TenantNote struct {
+	Id     int64
+	Tenant int64 `orm:"tenant"`
+	Text   string
+}
+
+func TestMemory_Tenant(t *testing.T) {
+	db := orm.OpenEvaluator(ormtest.NewMemory())
+	for tenant, text := range []string{"mine", "theirs"} {
+		res := orm.NewInsertor[TenantNote](db).Values(&TenantNote{Text: text}).
+			Exec(&middleware.Context{Ctx: orm.WithTenant(context.Background(), tenant+1)})
+		require.NoError(t, res.Err())
+	}
+	notes, err := orm.NewSelector[TenantNote](db).
+		GetMulti(&middleware.Context{Ctx: orm.WithTenant(context.Background(), 1)})
+	require.NoError(t, err)
+	assert.Equal(t, []*TenantNote{{Id: 1, Tenant: 1, Text: "mine"}}, notes)
+}
@@ -0,0 +1,264 @@
+// Package ormtest records the statements run against a real database into a
+// golden file and replays them later without any database.
+//
+//	sqldb := ormtest.Open(t, "testdata/user.golden", ormtest.WithSetup(createTables))
+//	db := orm.OpenDB(sqldb, orm.WithDialect(orm.SqliteDialect))
+//
+// Tests replay the golden file by default, run them with -ormtest.record to
+// record it again. Recording uses the sqlite3 driver unless WithDriver is
+// given; the driver has to be imported by the test.
+package ormtest
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/kisara71/go-orm/errs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var record = flag.Bool("ormtest.record", false, "record the ormtest golden files against the database")
+
+type config struct {
+	driver     string
+	dsn        string
+	setup      func(db *sql.DB) error
+	record     bool
+	ignoreArgs bool
+}
+
+type Option func(c *config)
+
+// WithDriver records against the database opened with driver and dsn
+// instead of an in-memory SQLite database.
+func WithDriver(driver, dsn string) Option {
+	return func(c *config) {
+		c.driver = driver
+		c.dsn = dsn
+	}
+}
+
+// WithSetup runs setup before recording, e.g. to create the tables. Its
+// statements are not recorded and it is not run on replay.
+func WithSetup(setup func(db *sql.DB) error) Option {
+	return func(c *config) {
+		c.setup = setup
+	}
+}
+
+// WithRecording records the golden file when record is true, whatever the
+// -ormtest.record flag says.
+func WithRecording(record bool) Option {
+	return func(c *config) {
+		c.record = record
+	}
+}
+
+// IgnoreArgs only compares the statements on replay, for arguments that
+// change on every run such as the current time.
+func IgnoreArgs() Option {
+	return func(c *config) {
+		c.ignoreArgs = true
+	}
+}
+
+// Open returns a database recording the statements run on it into golden,
+// or replaying them from it. On replay, a statement differing from the
+// recorded one fails t with a diff and returns errs.ErrGoldenMismatch.
+func Open(t testing.TB, golden string, opts ...Option) *sql.DB {
+	t.Helper()
+	c := &config{
+		driver: "sqlite3",
+		dsn:    ":memory:",
+		record: *record,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	tp := &tape{t: t, golden: golden, ignoreArgs: c.ignoreArgs}
+	if !c.record {
+		data, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("ormtest: %v, run the test with -ormtest.record to record it", err)
+		}
+		if err = json.Unmarshal(data, &tp.entries); err != nil {
+			t.Fatalf("ormtest: invalid golden file %s: %v", golden, err)
+		}
+		db := sql.OpenDB(&replayConnector{tape: tp})
+		t.Cleanup(func() {
+			_ = db.Close()
+			tp.done()
+		})
+		return db
+	}
+
+	under, err := sql.Open(c.driver, c.dsn)
+	if err != nil {
+		t.Fatalf("ormtest: %v", err)
+	}
+	db := sql.OpenDB(&recordConnector{tape: tp, db: under, dsn: c.dsn})
+	// a single connection, so that in-memory databases are seen by every
+	// statement
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = under.Close()
+		tp.save()
+	})
+	if c.setup != nil {
+		tp.pause(true)
+		err = c.setup(db)
+		tp.pause(false)
+		if err != nil {
+			t.Fatalf("ormtest: setup: %v", err)
+		}
+	}
+	return db
+}
+
+// entry is a statement of the golden file along with its result.
+type entry struct {
+	Op           string    `json:"op"`
+	SQL          string    `json:"sql,omitempty"`
+	Args         []value   `json:"args,omitempty"`
+	Columns      []string  `json:"columns,omitempty"`
+	Rows         [][]value `json:"rows,omitempty"`
+	LastInsertId int64     `json:"last_insert_id,omitempty"`
+	RowsAffected int64     `json:"rows_affected,omitempty"`
+	Err          string    `json:"error,omitempty"`
+}
+
+const (
+	opBegin    = "begin"
+	opCommit   = "commit"
+	opRollback = "rollback"
+	opExec     = "exec"
+	opQuery    = "query"
+)
+
+func (e entry) err() error {
+	if e.Err == "" {
+		return nil
+	}
+	return errors.New(e.Err)
+}
+
+// tape holds the entries being recorded or replayed, in the order the
+// statements run.
+type tape struct {
+	mu         sync.Mutex
+	t          testing.TB
+	golden     string
+	entries    []entry
+	pos        int
+	paused     bool
+	ignoreArgs bool
+}
+
+func (tp *tape) pause(paused bool) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.paused = paused
+}
+
+func (tp *tape) add(e entry) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if !tp.paused {
+		tp.entries = append(tp.entries, e)
+	}
+}
+
+// next returns the recorded entry of the statement about to run.
+func (tp *tape) next(got entry) (entry, error) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.pos >= len(tp.entries) {
+		tp.t.Errorf("ormtest: statement #%d is not in %s, which has %d\n+ %s",
+			tp.pos+1, tp.golden, len(tp.entries), got.String())
+		return entry{}, errs.ErrGoldenMismatch
+	}
+	want := tp.entries[tp.pos]
+	if d := diff(want, got, tp.ignoreArgs); d != "" {
+		tp.t.Errorf("ormtest: statement #%d differs from %s\n%s", tp.pos+1, tp.golden, d)
+		return entry{}, errs.ErrGoldenMismatch
+	}
+	tp.pos++
+	return want, nil
+}
+
+func (tp *tape) done() {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.pos < len(tp.entries) {
+		tp.t.Errorf("ormtest: %d statements of %s were not run, the first one is\n- %s",
+			len(tp.entries)-tp.pos, tp.golden, tp.entries[tp.pos].String())
+	}
+}
+
+func (tp *tape) save() {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(tp.entries)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(tp.golden), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(tp.golden, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		tp.t.Errorf("ormtest: %v", err)
+	}
+}
+
+func (e entry) String() string {
+	if e.SQL == "" {
+		return e.Op
+	}
+	return fmt.Sprintf("%s %s %s", e.Op, e.SQL, argsString(e.Args))
+}
+
+func argsString(args []value) string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(args)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// diff describes the differences between the recorded statement and the
+// one run, "" when they match.
+func diff(want, got entry, ignoreArgs bool) string {
+	var sb strings.Builder
+	line := func(name, w, g string) {
+		if w == g {
+			return
+		}
+		fmt.Fprintf(&sb, "%s:\n- %s\n+ %s\n  %s^\n", name, w, g, strings.Repeat(" ", commonPrefix(w, g)))
+	}
+	line("op", want.Op, got.Op)
+	line("sql", want.SQL, got.SQL)
+	if !ignoreArgs {
+		line("args", argsString(want.Args), argsString(got.Args))
+	}
+	return sb.String()
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package ormtest_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	orm "github.com/kisara71/go-orm"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/ormtest"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type User struct {
+	Id      int64
+	Name    string
+	Avatar  []byte
+	Score   float64
+	Created time.Time
+}
+
+func createUsers(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT, avatar BLOB, score REAL, created DATETIME)`)
+	return err
+}
+
+var created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// runUsers is the code under test, run against SQLite when recording and
+// against the golden file otherwise.
+func runUsers(t *testing.T, sqldb *sql.DB) []*User {
+	db := orm.OpenDB(sqldb, orm.WithDialect(orm.SqliteDialect))
+	ctx := func() *middleware.Context {
+		return &middleware.Context{Ctx: context.Background()}
+	}
+	res := orm.NewInsertor[User](db).Values(
+		&User{Id: 1, Name: "tom", Avatar: []byte{0xff, 0x00}, Score: 1.5, Created: created},
+		&User{Id: 2, Name: "jerry", Score: 2, Created: created},
+	).Exec(ctx())
+	require.NoError(t, res.Err())
+	err := db.DoTx(context.Background(), func(c context.Context, tx *orm.Transaction) error {
+		return orm.NewUpdater[User](tx).Set(orm.Assign("Name", "spike")).
+			Where(orm.C("Id").Eq(2)).Exec(&middleware.Context{Ctx: c}).Err()
+	})
+	require.NoError(t, err)
+	users, err := orm.NewSelector[User](db).Where(orm.C("Id").GT(0)).GetMulti(ctx())
+	require.NoError(t, err)
+	return users
+}
+
+var wantUsers = []*User{
+	{Id: 1, Name: "tom", Avatar: []byte{0xff, 0x00}, Score: 1.5, Created: created},
+	{Id: 2, Name: "spike", Score: 2, Created: created},
+}
+
+// TestGolden replays testdata/users.golden, run it with -ormtest.record to
+// record it again.
+func TestGolden(t *testing.T) {
+	sqldb := ormtest.Open(t, "testdata/users.golden", ormtest.WithSetup(createUsers))
+	assert.Equal(t, wantUsers, runUsers(t, sqldb))
+}
+
+func TestOpen(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "users.golden")
+	t.Run("record", func(t *testing.T) {
+		sqldb := ormtest.Open(t, golden, ormtest.WithRecording(true), ormtest.WithSetup(createUsers))
+		assert.Equal(t, wantUsers, runUsers(t, sqldb))
+	})
+	t.Run("replay", func(t *testing.T) {
+		sqldb := ormtest.Open(t, golden, ormtest.WithRecording(false))
+		assert.Equal(t, wantUsers, runUsers(t, sqldb))
+	})
+	t.Run("changed", func(t *testing.T) {
+		rt := &reportingT{T: t}
+		sqldb := ormtest.Open(rt, golden, ormtest.WithRecording(false))
+		db := orm.OpenDB(sqldb, orm.WithDialect(orm.SqliteDialect))
+		_, err := orm.NewSelector[User](db).Where(orm.C("Id").Eq(1)).
+			Get(&middleware.Context{Ctx: context.Background()})
+		assert.ErrorIs(t, err, errs.ErrGoldenMismatch)
+		require.Len(t, rt.reports, 1)
+		assert.Equal(t, "ormtest: statement #1 differs from "+golden+"\n"+
+			"op:\n"+
+			"- exec\n"+
+			"+ query\n"+
+			"  ^\n"+
+			"sql:\n"+
+			"- INSERT INTO \"user\" (\"id\", \"name\", \"avatar\", \"score\", \"created\") VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?);\n"+
+			"+ SELECT * FROM \"user\" WHERE \"id\" = ?;\n"+
+			"  ^\n"+
+			"args:\n"+
+			"- [1,\"tom\",{\"bytes\":\"/wA=\"},1.5,{\"time\":\"2024-01-02T03:04:05Z\"},2,\"jerry\",null,2,{\"time\":\"2024-01-02T03:04:05Z\"}]\n"+
+			"+ [1]\n"+
+			"    ^\n", rt.reports[0])
+	})
+}
+
+// reportingT keeps the errors reported by ormtest instead of failing.
+type reportingT struct {
+	*testing.T
+	reports []string
+}
+
+func (r *reportingT) Errorf(format string, args ...any) {
+	r.reports = append(r.reports, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,89 @@
+package ormtest
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// value is a driver.Value in the golden file. Integers, floats, strings,
+// booleans and NULL are plain JSON, bytes and times are objects:
+//
+//	{"bytes": "aGk="}
+//	{"time": "2024-01-02T03:04:05Z"}
+//
+// A float without fraction reads back as an int64, which scans the same.
+type value struct {
+	v driver.Value
+}
+
+type taggedValue struct {
+	Bytes []byte     `json:"bytes,omitempty"`
+	Time  *time.Time `json:"time,omitempty"`
+}
+
+func (v value) MarshalJSON() ([]byte, error) {
+	switch val := v.v.(type) {
+	case []byte:
+		if val == nil {
+			return []byte("null"), nil
+		}
+		return json.Marshal(taggedValue{Bytes: val})
+	case time.Time:
+		return json.Marshal(taggedValue{Time: &val})
+	case nil, int64, float64, bool, string:
+		return json.Marshal(val)
+	default:
+		return nil, fmt.Errorf("ormtest: unsupported driver value %T", val)
+	}
+}
+
+func (v *value) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var tagged taggedValue
+		if err := json.Unmarshal(data, &tagged); err != nil {
+			return err
+		}
+		switch {
+		case tagged.Time != nil:
+			v.v = *tagged.Time
+		case tagged.Bytes != nil:
+			v.v = tagged.Bytes
+		default:
+			v.v = []byte{}
+		}
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var val any
+	if err := dec.Decode(&val); err != nil {
+		return err
+	}
+	num, ok := val.(json.Number)
+	if !ok {
+		v.v = val
+		return nil
+	}
+	if !strings.ContainsAny(num.String(), ".eE") {
+		if i, err := num.Int64(); err == nil {
+			v.v = i
+			return nil
+		}
+	}
+	f, err := num.Float64()
+	v.v = f
+	return err
+}
+
+func valuesOf(args []driver.NamedValue) []value {
+	res := make([]value, 0, len(args))
+	for _, arg := range args {
+		res = append(res, value{v: arg.Value})
+	}
+	return res
+}
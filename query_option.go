@@ -0,0 +1,111 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/kisara71/go-orm/middleware"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// QueryOption configures a single statement, see Selector.With.
+type QueryOption func(opts *queryOptions)
+
+type queryOptions struct {
+	mdls    []middleware.Middleware
+	timeout time.Duration
+	tags    map[string]string
+	comment string
+	noCache bool
+}
+
+// WithMiddlewares runs mdls for this statement only, inside the ones
+// registered with DB.Use.
+func WithMiddlewares(mdls ...middleware.Middleware) QueryOption {
+	return func(opts *queryOptions) {
+		opts.mdls = append(opts.mdls, mdls...)
+	}
+}
+
+func WithTimeout(timeout time.Duration) QueryOption {
+	return func(opts *queryOptions) {
+		opts.timeout = timeout
+	}
+}
+
+// WithTag labels the statement, the tags are exposed to middlewares by
+// middleware.Context.Tags.
+func WithTag(key string, val string) QueryOption {
+	return func(opts *queryOptions) {
+		if opts.tags == nil {
+			opts.tags = make(map[string]string, 2)
+		}
+		opts.tags[key] = val
+	}
+}
+
+// WithComment prefixes the statement with /* comment */, e.g. an optimizer
+// hint or the caller for the slow query log.
+func WithComment(comment string) QueryOption {
+	return func(opts *queryOptions) {
+		opts.comment = strings.ReplaceAll(comment, "*/", "* /")
+	}
+}
+
+// WithoutCache skips the cache middleware for this statement.
+func WithoutCache() QueryOption {
+	return func(opts *queryOptions) {
+		opts.noCache = true
+	}
+}
+
+func (o queryOptions) clone() queryOptions {
+	o.mdls = slices.Clone(o.mdls)
+	o.tags = maps.Clone(o.tags)
+	return o
+}
+
+func (o *queryOptions) apply(opts []QueryOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// run runs h behind the global and the per statement middlewares. ctx.Ctx
+// is restored when it returns.
+func (c core) run(ctx *middleware.Context, opts queryOptions, h middleware.Handler) *middleware.Result {
+	defer func(orig context.Context) {
+		ctx.Ctx = orig
+	}(ctx.Ctx)
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx.Ctx, cancel = context.WithTimeout(ctx.Ctx, opts.timeout)
+		defer cancel()
+	}
+	if opts.noCache {
+		ctx.Ctx = middleware.WithoutCache(ctx.Ctx)
+	}
+	if len(opts.tags) > 0 {
+		if ctx.Tags == nil {
+			ctx.Tags = make(map[string]string, len(opts.tags))
+		}
+		for k, v := range opts.tags {
+			ctx.Tags[k] = v
+		}
+	}
+	h = chain(opts.mdls, h)
+	if c.chain == nil {
+		return h(ctx)
+	}
+	// restored as well, the handler may run statements with ctx
+	defer ctx.SetHandler(ctx.SetHandler(h))
+	return c.chain(ctx)
+}
+
+func chain(mdls []middleware.Middleware, root middleware.Handler) middleware.Handler {
+	for i := len(mdls) - 1; i >= 0; i-- {
+		root = mdls[i](root)
+	}
+	return root
+}
@@ -0,0 +1,171 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/middleware/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestQueryOption(t *testing.T) {
+	type TestModel struct {
+		Id   int64
+		Name string
+	}
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	var (
+		composed int
+		calls    []string
+	)
+	record := func(name string) middleware.Middleware {
+		return func(next middleware.Handler) middleware.Handler {
+			if name == "global" {
+				composed++
+			}
+			return func(ctx *middleware.Context) *middleware.Result {
+				calls = append(calls, name)
+				return next(ctx)
+			}
+		}
+	}
+	db.Use(record("global"))
+
+	testCases := []struct {
+		name      string
+		run       func(ctx *middleware.Context) error
+		query     string
+		exec      bool
+		wantCalls []string
+		check     func(t *testing.T, ctx *middleware.Context)
+	}{
+		{
+			name: "no option",
+			run: func(ctx *middleware.Context) error {
+				_, err := NewSelector[TestModel](db).GetMulti(ctx)
+				return err
+			},
+			query:     "SELECT * FROM `test_model`;",
+			wantCalls: []string{"global"},
+		},
+		{
+			name: "per statement middleware",
+			run: func(ctx *middleware.Context) error {
+				_, err := NewSelector[TestModel](db).
+					With(WithMiddlewares(record("first"), record("second"))).
+					GetMulti(ctx)
+				return err
+			},
+			query:     "SELECT * FROM `test_model`;",
+			wantCalls: []string{"global", "first", "second"},
+		},
+		{
+			name: "comment and tags",
+			run: func(ctx *middleware.Context) error {
+				return NewUpdater[TestModel](db).Set(Assign("Name", "tom")).
+					With(WithComment("cron */ job"), WithTag("service", "billing")).
+					Exec(ctx).Err()
+			},
+			query:     "/* cron * / job */ UPDATE `test_model` SET `name` = ?;",
+			exec:      true,
+			wantCalls: []string{"global"},
+			check: func(t *testing.T, ctx *middleware.Context) {
+				assert.Equal(t, map[string]string{"service": "billing"}, ctx.Tags)
+			},
+		},
+		{
+			name: "timeout",
+			run: func(ctx *middleware.Context) error {
+				return NewDeletor[TestModel](db).
+					With(WithTimeout(time.Minute), WithMiddlewares(func(next middleware.Handler) middleware.Handler {
+						return func(ctx *middleware.Context) *middleware.Result {
+							_, ok := ctx.Ctx.Deadline()
+							assert.True(t, ok)
+							return next(ctx)
+						}
+					})).
+					Exec(ctx).Err()
+			},
+			query:     "DELETE FROM `test_model`",
+			exec:      true,
+			wantCalls: []string{"global"},
+			check: func(t *testing.T, ctx *middleware.Context) {
+				// the caller's context is given back
+				_, ok := ctx.Ctx.Deadline()
+				assert.False(t, ok)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = nil
+			if tc.exec {
+				mock.ExpectExec(tc.query).WillReturnResult(sqlmock.NewResult(0, 1))
+			} else {
+				mock.ExpectQuery(tc.query).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+			ctx := &middleware.Context{Ctx: context.Background()}
+			require.NoError(t, tc.run(ctx))
+			assert.Equal(t, tc.wantCalls, calls)
+			if tc.check != nil {
+				tc.check(t, ctx)
+			}
+		})
+	}
+	// the global chain is composed by Use, not on every call
+	assert.Equal(t, 1, composed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryOption_WithoutCache(t *testing.T) {
+	type TestModel struct {
+		Id int64
+	}
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	db.Use(cache.New(cache.NewLRU(8), time.Minute).Build())
+
+	query := "SELECT * FROM `test_model`;"
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	for i := 0; i < 2; i++ {
+		_, err = NewSelector[TestModel](db).With(WithoutCache()).
+			GetMulti(&middleware.Context{Ctx: context.Background()})
+		require.NoError(t, err)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryOption_ReplacedContext(t *testing.T) {
+	type TestModel struct {
+		Id int64
+	}
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	// a middleware may replace ctx.Ctx by a context not derived from it
+	db.Use(func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			ctx.Ctx = context.Background()
+			return next(ctx)
+		}
+	})
+
+	mock.ExpectQuery("SELECT * FROM `test_model`;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	res, err := NewSelector[TestModel](db).Get(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1}, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	res2 := middleware.Dispatch(&middleware.Context{Ctx: context.Background()})
+	assert.Equal(t, errs.ErrNoHandler, res2.Err)
+}
@@ -0,0 +1,243 @@
+package go_orm
+
+import (
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"iter"
+)
+
+// RawQuerier runs a hand-written query and scans its rows into T, matching
+// the columns to the fields as Selector does. See RawQuery.
+type RawQuerier[T any] struct {
+	core  core
+	sess  Session
+	query string
+	args  []any
+	opts  queryOptions
+}
+
+// RawQuery returns a querier running query with args. Placeholders are
+// written as the dialect writes them, ? or $1, $2... for PostgreSQL, and
+// must match args. The statements run behind the middlewares as middleware.KindRaw,
+// with no tables: the cache middleware neither serves nor invalidates them.
+func RawQuery[T any](sess Session, query string, args ...any) *RawQuerier[T] {
+	return &RawQuerier[T]{
+		core:  sess.getCore(),
+		sess:  sess,
+		query: query,
+		args:  args,
+	}
+}
+
+// With sets options and middlewares for this statement only.
+func (r *RawQuerier[T]) With(opts ...QueryOption) *RawQuerier[T] {
+	r.opts.apply(opts)
+	return r
+}
+
+func (r *RawQuerier[T]) build(ctx *middleware.Context) error {
+	m, err := r.core.registry.Get(new(T))
+	if err != nil {
+		return err
+	}
+	if err = checkRaw(r.core, r.query, r.args); err != nil {
+		return err
+	}
+	ctx.Model = m
+	ctx.Type = middleware.OpQuery
+	ctx.Kind = middleware.KindRaw
+	ctx.SetStatement(r.query)
+	ctx.SetArgs(r.args)
+	return nil
+}
+
+func (r *RawQuerier[T]) Get(ctx *middleware.Context) (*T, error) {
+	if err := r.build(ctx); err != nil {
+		return nil, err
+	}
+	ctx.Single = true
+	res := r.core.run(ctx, r.opts, func(ctx *middleware.Context) *middleware.Result {
+		var t *T
+		err := r.each(ctx, func(row *T) bool {
+			t = row
+			return false
+		})
+		if err == nil && t == nil {
+			err = errs.ErrNoRecord
+		}
+		if err == nil {
+			err = afterFind(ctx.Ctx, r.sess, t)
+		}
+		return &middleware.Result{
+			Res: t,
+			Err: err,
+		}
+	})
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return res.Res.(*T), nil
+}
+
+func (r *RawQuerier[T]) GetMulti(ctx *middleware.Context) ([]*T, error) {
+	if err := r.build(ctx); err != nil {
+		return nil, err
+	}
+	res := r.core.run(ctx, r.opts, func(ctx *middleware.Context) *middleware.Result {
+		res := make([]*T, 0, 32)
+		err := r.each(ctx, func(row *T) bool {
+			res = append(res, row)
+			return true
+		})
+		if err != nil {
+			return &middleware.Result{
+				Res: nil,
+				Err: err,
+			}
+		}
+		for _, t := range res {
+			if err = afterFind(ctx.Ctx, r.sess, t); err != nil {
+				return &middleware.Result{
+					Res: nil,
+					Err: err,
+				}
+			}
+		}
+		return &middleware.Result{
+			Res: res,
+			Err: nil,
+		}
+	})
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return res.Res.([]*T), nil
+}
+
+// Iter yields the rows one at a time, stopping at the first error. The rows
+// are yielded from within the middlewares, which see the whole iteration.
+// The AfterFind hooks and the loop body run while the rows are still open
+// and hold a connection: running statements from them needs another one,
+// they wait forever on a pool of a single connection.
+func (r *RawQuerier[T]) Iter(ctx *middleware.Context) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		if err := r.build(ctx); err != nil {
+			yield(nil, err)
+			return
+		}
+		stopped := false
+		res := r.core.run(ctx, r.opts, func(ctx *middleware.Context) *middleware.Result {
+			err := r.each(ctx, func(t *T) bool {
+				if err := afterFind(ctx.Ctx, r.sess, t); err != nil {
+					yield(nil, err)
+					stopped = true
+					return false
+				}
+				stopped = !yield(t, nil)
+				return !stopped
+			})
+			return &middleware.Result{
+				Res: nil,
+				Err: err,
+			}
+		})
+		if res.Err != nil && !stopped {
+			yield(nil, res.Err)
+		}
+	}
+}
+
+// each scans the rows of the statement held by ctx and hands them to fn
+// until it returns false.
+func (r *RawQuerier[T]) each(ctx *middleware.Context, fn func(t *T) bool) error {
+	rows, err := r.sess.queryContext(ctx.Ctx, ctx.Statement, ctx.Args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	uac := r.core.accessor(ctx.Model)
+	for rows.Next() {
+		t := new(T)
+		if err = scanRow(uac, t, rows, cols); err != nil {
+			return err
+		}
+		if !fn(t) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+// RawExecutor runs a hand-written statement, see RawExec.
+type RawExecutor struct {
+	core  core
+	sess  Session
+	query string
+	args  []any
+	opts  queryOptions
+}
+
+// RawExec returns an executor running query with args, see RawQuery.
+func RawExec(sess Session, query string, args ...any) *RawExecutor {
+	return &RawExecutor{
+		core:  sess.getCore(),
+		sess:  sess,
+		query: query,
+		args:  args,
+	}
+}
+
+// With sets options and middlewares for this statement only.
+func (r *RawExecutor) With(opts ...QueryOption) *RawExecutor {
+	r.opts.apply(opts)
+	return r
+}
+
+func (r *RawExecutor) Exec(ctx *middleware.Context) *ExecResult {
+	if err := checkRaw(r.core, r.query, r.args); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
+	}
+	ctx.Type = middleware.OpExec
+	ctx.Kind = middleware.KindRaw
+	ctx.SetStatement(r.query)
+	ctx.SetArgs(r.args)
+	res := r.core.run(ctx, r.opts, func(ctx *middleware.Context) *middleware.Result {
+		res, err := r.sess.execContext(ctx.Ctx, ctx.Statement, ctx.Args...)
+		if err != nil {
+			return &middleware.Result{
+				Res: nil,
+				Err: err,
+			}
+		}
+		return &middleware.Result{
+			Res: &ExecResult{
+				res: res,
+				err: nil,
+			},
+			Err: nil,
+		}
+	})
+	if res.Err != nil {
+		return &ExecResult{
+			res: nil,
+			err: res.Err,
+		}
+	}
+	return res.Res.(*ExecResult)
+}
+
+// checkRaw fails when the placeholders of query, read as the dialect writes
+// them, do not match args.
+func checkRaw(c core, query string, args []any) error {
+	if arity(placeholders(c.dialect, query)) != len(args) {
+		return errs.ErrInvalidArguments
+	}
+	return nil
+}
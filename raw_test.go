@@ -0,0 +1,198 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRawQuery(t *testing.T) {
+	type TestModel struct {
+		Id      int64
+		Name    string
+		Address sql.NullString
+	}
+	query := "SELECT `id`, `name` FROM `test_model` WHERE `name` LIKE ?;"
+	testCases := []struct {
+		name     string
+		expect   func(mk sqlmock.Sqlmock)
+		query    string
+		args     []any
+		wantOne  *TestModel
+		wantMany []*TestModel
+		wantErr  error
+	}{
+		{
+			name: "rows",
+			expect: func(mk sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom").AddRow(2, "tim")
+				mk.ExpectQuery(query).WithArgs("t%").WillReturnRows(rows)
+			},
+			query:    query,
+			args:     []any{"t%"},
+			wantOne:  &TestModel{Id: 1, Name: "tom"},
+			wantMany: []*TestModel{{Id: 1, Name: "tom"}, {Id: 2, Name: "tim"}},
+		},
+		{
+			name: "no rows",
+			expect: func(mk sqlmock.Sqlmock) {
+				mk.ExpectQuery(query).WithArgs("x%").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+			},
+			query:    query,
+			args:     []any{"x%"},
+			wantErr:  errs.ErrNoRecord,
+			wantMany: []*TestModel{},
+		},
+		{
+			name: "unknown column",
+			expect: func(mk sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "age"}).AddRow(1, 18)
+				mk.ExpectQuery("SELECT * FROM `test_model`;").WillReturnRows(rows)
+			},
+			query:   "SELECT * FROM `test_model`;",
+			wantErr: errs.ErrUnknownColumn,
+		},
+		{
+			name:    "missing args",
+			expect:  func(mk sqlmock.Sqlmock) {},
+			query:   query,
+			wantErr: errs.ErrInvalidArguments,
+		},
+		{
+			name:    "quoted placeholders",
+			expect:  func(mk sqlmock.Sqlmock) {},
+			query:   "SELECT `id?` FROM `test_model` WHERE `name` = '?';",
+			args:    []any{1},
+			wantErr: errs.ErrInvalidArguments,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			db := OpenDB(mockDB, WithDialect(MySQLDialect))
+			var kinds []middleware.OpKind
+			db.Use(func(next middleware.Handler) middleware.Handler {
+				return func(ctx *middleware.Context) *middleware.Result {
+					kinds = append(kinds, ctx.Kind)
+					return next(ctx)
+				}
+			})
+
+			tc.expect(mock)
+			one, err := RawQuery[TestModel](db, tc.query, tc.args...).Get(&middleware.Context{Ctx: context.Background()})
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantOne, one)
+
+			tc.expect(mock)
+			many, err := RawQuery[TestModel](db, tc.query, tc.args...).GetMulti(&middleware.Context{Ctx: context.Background()})
+			if tc.wantErr != errs.ErrNoRecord {
+				assert.ErrorIs(t, err, tc.wantErr)
+			}
+			assert.Equal(t, tc.wantMany, many)
+			require.NoError(t, mock.ExpectationsWereMet())
+			for _, kind := range kinds {
+				assert.Equal(t, middleware.KindRaw, kind)
+			}
+		})
+	}
+}
+
+func TestRawQuerier_Iter(t *testing.T) {
+	type TestModel struct {
+		Id   int64
+		Name string
+	}
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	query := "SELECT * FROM `test_model`;"
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tom").AddRow(2, "tim").AddRow(3, "tam")
+	}
+	// the middlewares wrap the whole iteration
+	var open bool
+	db.Use(func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			open = true
+			defer func() { open = false }()
+			return next(ctx)
+		}
+	})
+
+	mock.ExpectQuery(query).WillReturnRows(newRows())
+	var names []string
+	for row, err := range RawQuery[TestModel](db, query).Iter(&middleware.Context{Ctx: context.Background()}) {
+		require.NoError(t, err)
+		assert.True(t, open)
+		names = append(names, row.Name)
+	}
+	assert.Equal(t, []string{"tom", "tim", "tam"}, names)
+	assert.False(t, open)
+
+	mock.ExpectQuery(query).WillReturnRows(newRows()).RowsWillBeClosed()
+	names = names[:0]
+	for row, err := range RawQuery[TestModel](db, query).Iter(&middleware.Context{Ctx: context.Background()}) {
+		require.NoError(t, err)
+		names = append(names, row.Name)
+		if len(names) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"tom", "tim"}, names)
+
+	mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+	calls := 0
+	for row, err := range RawQuery[TestModel](db, query).Iter(&middleware.Context{Ctx: context.Background()}) {
+		calls++
+		assert.Nil(t, row)
+		assert.Equal(t, sql.ErrConnDone, err)
+	}
+	assert.Equal(t, 1, calls)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRawExec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	var stmt *middleware.Context
+	db.Use(func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			stmt = ctx
+			return next(ctx)
+		}
+	})
+
+	mock.ExpectExec("UPDATE `test_model` SET `age` = `age` + ? WHERE `id` = ?;").
+		WithArgs(1, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	res := RawExec(db, "UPDATE `test_model` SET `age` = `age` + ? WHERE `id` = ?;", 1, 7).
+		Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	assert.Equal(t, middleware.KindRaw, stmt.Kind)
+	assert.Equal(t, middleware.OpExec, stmt.Type)
+
+	res = RawExec(db, "DELETE FROM `test_model` WHERE `id` = ?;").
+		Exec(&middleware.Context{Ctx: context.Background()})
+	assert.Equal(t, errs.ErrInvalidArguments, res.Err())
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// PostgreSQL numbers the placeholders, ? is the jsonb operator
+	pg := OpenDB(mockDB, WithDialect(PostGreDialect))
+	query := `UPDATE "test_model" SET "age" = $1 WHERE "tags" ? 'vip' AND "id" = $2;`
+	mock.ExpectExec(query).WithArgs(18, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	res = RawExec(pg, query, 18, 7).Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+	res = RawExec(pg, `DELETE FROM "test_model" WHERE "id" = ?;`, 7).
+		Exec(&middleware.Context{Ctx: context.Background()})
+	assert.Equal(t, errs.ErrInvalidArguments, res.Err())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,94 @@
+package go_orm
+
+import (
+	"database/sql"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/model"
+	"reflect"
+)
+
+// reflectAccessor has the same semantics as unsafeAccessor but only goes
+// through reflect.Value, for deployments where importing package unsafe is
+// not allowed. The unexported fields, which reflect only reaches through
+// package unsafe, fail with errs.ErrUnexportedField.
+type reflectAccessor struct {
+	m      *model.Model
+	entity reflect.Value
+}
+
+func NewReflectAccessor(model *model.Model) UnsafeAccessor {
+	return &reflectAccessor{
+		m: model,
+	}
+}
+
+func (r *reflectAccessor) Access(entity any) {
+	r.entity = reflect.ValueOf(entity)
+}
+
+func (r *reflectAccessor) Set(rows *sql.Rows) error {
+	if !r.entity.IsValid() {
+		return errs.ErrNoEntity
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	val := r.entity.Elem()
+	vals := make([]any, 0, len(cols))
+	for _, col := range cols {
+		fd, ok := r.m.ColMap[col]
+		if !ok {
+			return errs.ErrUnknownColumn
+		}
+		fv, err := reflectField(val, fd, true)
+		if err != nil {
+			return err
+		}
+		vals = append(vals, fv.Addr().Interface())
+	}
+	err = rows.Scan(vals...)
+	if err != nil {
+		return errs.ErrScanFailed
+	}
+	return nil
+}
+
+func (r *reflectAccessor) Fetch(field string) (any, error) {
+	if !r.entity.IsValid() {
+		return nil, errs.ErrNoEntity
+	}
+	fd, ok := r.m.GoMap[field]
+	if !ok {
+		return nil, errs.ErrUnknownField
+	}
+	fv, err := reflectField(r.entity.Elem(), fd, false)
+	if err != nil {
+		return nil, err
+	}
+	return fv.Interface(), nil
+}
+
+// reflectField returns the field fd of val. The nil embedded pointers on the
+// way are allocated when alloc, the field reads as zero otherwise.
+func reflectField(val reflect.Value, fd *model.FieldInfo, alloc bool) (reflect.Value, error) {
+	for i, idx := range fd.Index {
+		if i > 0 && val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				if !alloc {
+					return reflect.Zero(fd.Type), nil
+				}
+				if !val.CanSet() {
+					return reflect.Value{}, errs.ErrUnexportedField
+				}
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(idx)
+	}
+	if !val.CanInterface() || (alloc && !val.CanSet()) {
+		return reflect.Value{}, errs.ErrUnexportedField
+	}
+	return val, nil
+}
@@ -5,8 +5,10 @@ import (
 	"github.com/kisara71/go-orm/model"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type customTable struct {
@@ -17,12 +19,32 @@ func (c *customTable) TableName() string {
 	return "my_custom_table"
 }
 
+type registryTestBase struct {
+	Id int64
+}
+
+type registryTestAudit struct {
+	CreatedBy string `orm:"column=creator"`
+}
+
 func TestRegistry_ParseModel(t *testing.T) {
 	type TestModel struct {
 		Id   int    `orm:"column=id_t"`
 		Name string // 没有 tag，用 CamelToSnake
 		Age  int    `orm:"column=age_t"`
 	}
+	type EmbeddedModel struct {
+		registryTestBase
+		*registryTestAudit
+		Name string
+		// embedded times and tagged structs are columns
+		time.Time
+		Inner registryTestBase
+	}
+	type DuplicateModel struct {
+		registryTestBase
+		Id int64
+	}
 	r := &model.Registry{}
 
 	testCases := []struct {
@@ -63,6 +85,23 @@ func TestRegistry_ParseModel(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name:    "embedded",
+			entity:  &EmbeddedModel{},
+			wantTbl: "embedded_model",
+			wantCol: map[string]string{
+				"Id":        "id",
+				"CreatedBy": "creator",
+				"Name":      "name",
+				"Time":      "time",
+				"Inner":     "inner",
+			},
+		},
+		{
+			name:    "duplicate embedded field",
+			entity:  &DuplicateModel{},
+			wantErr: errs.ErrInvalidModel,
+		},
 		{
 			name:    "invalid type",
 			entity:  123,
@@ -86,3 +125,29 @@ func TestRegistry_ParseModel(t *testing.T) {
 		})
 	}
 }
+
+func TestFieldInfo_Embedded(t *testing.T) {
+	type TestModel struct {
+		*registryTestAudit
+		Name string
+	}
+	m, err := (&model.Registry{}).Get(&TestModel{})
+	require.NoError(t, err)
+	fd := m.GoMap["CreatedBy"]
+	assert.Equal(t, []int{0, 0}, fd.Index)
+	assert.True(t, fd.Indirect)
+	assert.False(t, m.GoMap["Name"].Indirect)
+
+	// a nil embedded pointer reads as zero and is allocated when set
+	entity := &TestModel{}
+	assert.Equal(t, "", fd.Value(reflect.ValueOf(entity).Elem()).Interface())
+	fd.Settable(reflect.ValueOf(entity).Elem()).SetString("tom")
+	assert.Equal(t, &TestModel{registryTestAudit: &registryTestAudit{CreatedBy: "tom"}}, entity)
+
+	// setting a detached copy leaves the original untouched
+	c := *entity
+	fd.Detach(reflect.ValueOf(&c).Elem())
+	fd.Settable(reflect.ValueOf(&c).Elem()).SetString("jerry")
+	assert.Equal(t, "tom", entity.CreatedBy)
+	assert.Equal(t, "jerry", c.CreatedBy)
+}
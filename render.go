@@ -0,0 +1,186 @@
+package go_orm
+
+import (
+	"github.com/kisara71/go-orm/errs"
+	"strconv"
+	"strings"
+)
+
+// Render replaces the placeholders of query by args written as literals of
+// d, for display only: run the statements with their args.
+// The placeholders inside string literals, quoted identifiers and comments
+// are kept.
+func Render(d Dialect, query string, args []any) (string, error) {
+	ps := placeholders(d, query)
+	if arity(ps) != len(args) {
+		return "", errs.ErrInvalidArguments
+	}
+	var sb strings.Builder
+	sb.Grow(len(query) + 8*len(args))
+	last := 0
+	for _, p := range ps {
+		lit, err := d.Literal(normalize(args[p.idx]))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(query[last:p.pos])
+		sb.WriteString(lit)
+		last = p.end
+	}
+	sb.WriteString(query[last:])
+	return sb.String(), nil
+}
+
+// placeholder is the placeholder query[pos:end] of a statement, standing for
+// args[idx].
+type placeholder struct {
+	pos, end, idx int
+}
+
+// placeholders returns the placeholders of query as d writes them. Where they
+// are numbered, ? is not a placeholder but an operator, as the jsonb ?, ?|
+// and ?& of PostgreSQL.
+func placeholders(d Dialect, query string) []placeholder {
+	return scanPlaceholders(query, d.Quoter(), d.numbered(), d.backslashEscapes())
+}
+
+// scanPlaceholders returns the ? or, when numbered, the $1, $2... of query,
+// skipping the string literals, in which a backslash escapes the next
+// character when backslash is set, the identifiers quoted with qte, the
+// dollar quoted strings of numbered dialects and the comments.
+func scanPlaceholders(query string, qte byte, numbered, backslash bool) []placeholder {
+	var res []placeholder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == qte:
+			// a doubled quote is read as two literals
+			end := closingQuote(query[i+1:], c, backslash && c == '\'')
+			if end < 0 {
+				return res
+			}
+			i += end + 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return res
+			}
+			i += end + 3
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i+2:], '\n')
+			if end < 0 {
+				return res
+			}
+			i += end + 2
+		case c == '?' && !numbered:
+			res = append(res, placeholder{pos: i, end: i + 1, idx: len(res)})
+		case c == '$' && numbered:
+			end := i + 1
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			if end > i+1 {
+				// $0 and overflows are kept as invalid indexes
+				n, err := strconv.Atoi(query[i+1 : end])
+				if err != nil {
+					n = 0
+				}
+				res = append(res, placeholder{pos: i, end: end, idx: n - 1})
+				i = end - 1
+				continue
+			}
+			tag := dollarTag(query[i:])
+			if tag == "" {
+				continue
+			}
+			end = strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				return res
+			}
+			i += len(tag) + end + len(tag) - 1
+		}
+	}
+	return res
+}
+
+// closingQuote returns the index in s of the quote q closing a literal, -1
+// when there is none. The quotes escaped by a backslash are skipped when
+// backslash is set.
+func closingQuote(s string, q byte, backslash bool) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case q:
+			return i
+		case '\\':
+			if backslash {
+				i++
+			}
+		}
+	}
+	return -1
+}
+
+// dollarTag returns the $tag$ opening the dollar quoted string s starts
+// with, "" when there is none.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// arity returns the number of args ps refer to, -1 when they skip some.
+func arity(ps []placeholder) int {
+	n := 0
+	for _, p := range ps {
+		if p.idx < 0 || p.idx >= len(ps) {
+			return -1
+		}
+		n = max(n, p.idx+1)
+	}
+	seen := make([]bool, n)
+	for _, p := range ps {
+		seen[p.idx] = true
+	}
+	for _, ok := range seen {
+		if !ok {
+			return -1
+		}
+	}
+	return n
+}
+
+// number rewrites the ? of a statement written by the builders as $1, $2...
+func number(query string, qte byte) string {
+	ps := scanPlaceholders(query, qte, false, false)
+	if len(ps) == 0 {
+		return query
+	}
+	var sb strings.Builder
+	sb.Grow(len(query) + 2*len(ps))
+	last := 0
+	for _, p := range ps {
+		sb.WriteString(query[last:p.pos])
+		sb.WriteByte('$')
+		sb.WriteString(strconv.Itoa(p.idx + 1))
+		last = p.end
+	}
+	sb.WriteString(query[last:])
+	return sb.String()
+}
+
+// toSQL builds b as for context.Background() and renders it, see Render.
+func toSQL(b Builder, d Dialect) (string, error) {
+	q, err := b.Build()
+	if err != nil {
+		return "", err
+	}
+	return Render(d, q.SQL, q.Args)
+}
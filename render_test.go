@@ -0,0 +1,236 @@
+package go_orm
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.FixedZone("", 3600))
+	name := "tom"
+	args := []any{nil, 3, uint8(4), 1.5, true, "it's a\\b", []byte{0xff, 0x00}, at,
+		sql.NullString{}, &name, (*int)(nil)}
+	query := "INSERT INTO t VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		args    []any
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "standard",
+			dialect: StandardSQL,
+			query:   query,
+			args:    args,
+			want: "INSERT INTO t VALUES (NULL, 3, 4, 1.5, TRUE, 'it''s a\\b', X'ff00', " +
+				"'2024-01-02 03:04:05.6+01:00', NULL, 'tom', NULL);",
+		},
+		{
+			name:    "mysql",
+			dialect: MySQLDialect,
+			query:   query,
+			args:    args,
+			want: "INSERT INTO t VALUES (NULL, 3, 4, 1.5, TRUE, 'it\\'s a\\\\b', X'ff00', " +
+				"'2024-01-02 02:04:05.6', NULL, 'tom', NULL);",
+		},
+		{
+			name:    "sqlite",
+			dialect: SqliteDialect,
+			query:   query,
+			args:    args,
+			want: "INSERT INTO t VALUES (NULL, 3, 4, 1.5, 1, 'it''s a\\b', X'ff00', " +
+				"'2024-01-02 03:04:05.6+01:00', NULL, 'tom', NULL);",
+		},
+		{
+			name:    "postgres",
+			dialect: PostGreDialect,
+			query:   "INSERT INTO t VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);",
+			args:    args,
+			want: "INSERT INTO t VALUES (NULL, 3, 4, 1.5, TRUE, 'it''s a\\b', '\\xff00'::bytea, " +
+				"'2024-01-02 03:04:05.6+01:00', NULL, 'tom', NULL);",
+		},
+		{
+			name:    "quoted placeholders",
+			dialect: MySQLDialect,
+			query:   "/* why? */ SELECT `a?` FROM t WHERE b = 'it''s?' AND c = ?;",
+			args:    []any{"x"},
+			want:    "/* why? */ SELECT `a?` FROM t WHERE b = 'it''s?' AND c = 'x';",
+		},
+		{
+			name:    "postgres line comments",
+			dialect: PostGreDialect,
+			query:   "-- why $1?\nSELECT * FROM t WHERE a = $1; -- or $2",
+			args:    []any{1},
+			want:    "-- why $1?\nSELECT * FROM t WHERE a = 1; -- or $2",
+		},
+		{
+			name:    "mysql line comments",
+			dialect: MySQLDialect,
+			query:   "SELECT * -- why?\nFROM t WHERE a = ?;",
+			args:    []any{1},
+			want:    "SELECT * -- why?\nFROM t WHERE a = 1;",
+		},
+		{
+			name:    "mysql backslash escapes",
+			dialect: MySQLDialect,
+			query:   `SELECT 'a\', ?, '?';`,
+			args:    []any{1},
+			want:    `SELECT 'a\', ?, '1';`,
+		},
+		{
+			name:    "standard backslash",
+			dialect: StandardSQL,
+			query:   `SELECT 'a\', ?, '?';`,
+			args:    []any{1},
+			want:    `SELECT 'a\', 1, '?';`,
+		},
+		{
+			name:    "postgres operators",
+			dialect: PostGreDialect,
+			query:   `SELECT $$it's $1$$ FROM t WHERE "a$1" ? 'k' AND b ?| $2 AND c = $1 AND d ?& $tag$$2$tag$;`,
+			args:    []any{"x", "y"},
+			want:    `SELECT $$it's $1$$ FROM t WHERE "a$1" ? 'k' AND b ?| 'y' AND c = 'x' AND d ?& $tag$$2$tag$;`,
+		},
+		{
+			name:    "postgres skipped args",
+			dialect: PostGreDialect,
+			query:   "SELECT * FROM t WHERE a = $1 AND b = $3;",
+			args:    []any{1, 2, 3},
+			wantErr: errs.ErrInvalidArguments,
+		},
+		{
+			name:    "missing args",
+			dialect: MySQLDialect,
+			query:   "SELECT * FROM t WHERE a = ? AND b = ?;",
+			args:    []any{1},
+			wantErr: errs.ErrInvalidArguments,
+		},
+		{
+			name:    "extra args",
+			dialect: MySQLDialect,
+			query:   "SELECT * FROM t;",
+			args:    []any{1},
+			wantErr: errs.ErrInvalidArguments,
+		},
+		{
+			name:    "unsupported type",
+			dialect: MySQLDialect,
+			query:   "SELECT * FROM t WHERE a = ?;",
+			args:    []any{struct{}{}},
+			wantErr: errs.ErrUnsupportedType,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Render(tc.dialect, tc.query, tc.args)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestToSQL(t *testing.T) {
+	type TestModel struct {
+		Id        int64
+		FirstName string
+		Age       int
+	}
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	res, err := NewSelector[TestModel](db).Where(C("FirstName").Eq("tom")).Limit(1).ToSQL()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `test_model` WHERE `first_name` = 'tom' LIMIT 1;", res)
+
+	res, err = NewInsertor[TestModel](db).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).ToSQL()
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `test_model` (`id`, `age`) VALUES (1, 18);", res)
+
+	res, err = NewUpdater[TestModel](db).Set(Assign("Age", 19)).Where(C("Id").Eq(1)).ToSQL()
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE `test_model` SET `age` = 19 WHERE `id` = 1;", res)
+
+	d := NewDeletor[TestModel](db)
+	d.Where(C("Id").Eq(1))
+	res, err = d.ToSQL()
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM `test_model` WHERE `id` = 1", res)
+
+	_, err = NewInsertor[TestModel](db).ToSQL()
+	assert.Equal(t, errs.ErrInsertNoValues, err)
+
+	// the placeholders are numbered for PostgreSQL
+	pg := OpenDB(mockDB, WithDialect(PostGreDialect))
+	q, err := NewSelector[TestModel](pg).Where(C("FirstName").Eq("tom").And(C("Age").GT(18))).Limit(1).Build()
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "test_model" WHERE ("first_name" = $1) AND ("age" > $2) LIMIT $3;`, q.SQL)
+	res, err = NewSelector[TestModel](pg).Where(C("FirstName").Eq("tom").And(C("Age").GT(18))).Limit(1).ToSQL()
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "test_model" WHERE ("first_name" = 'tom') AND ("age" > 18) LIMIT 1;`, res)
+}
+
+func TestBuild_Numbered(t *testing.T) {
+	type TestModel struct {
+		Id        int64
+		FirstName string
+		Age       int
+	}
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(PostGreDialect))
+	deletor := NewDeletor[TestModel](db)
+	deletor.Where(C("Id").Eq(1).Or(C("Age").LT(2)))
+
+	testCases := []struct {
+		name     string
+		builder  Builder
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name: "select",
+			builder: NewSelector[TestModel](db).Where(C("FirstName").Eq("tom"), C("Age").GT(18)).
+				Limit(10).Offset(20),
+			wantSQL:  `SELECT * FROM "test_model" WHERE ("first_name" = $1) AND ("age" > $2) LIMIT $3 OFFSET $4;`,
+			wantArgs: []any{"tom", 18, int64(10), int64(20)},
+		},
+		{
+			name:     "update",
+			builder:  NewUpdater[TestModel](db).Set(Assign("FirstName", "tom"), Assign("Age", 18)).Where(C("Id").Eq(1)),
+			wantSQL:  `UPDATE "test_model" SET "first_name" = $1, "age" = $2 WHERE "id" = $3;`,
+			wantArgs: []any{"tom", 18, 1},
+		},
+		{
+			name:     "delete",
+			builder:  deletor,
+			wantSQL:  `DELETE FROM "test_model" WHERE ("id" = $1) OR ("age" < $2)`,
+			wantArgs: []any{1, 2},
+		},
+		{
+			name: "insert",
+			builder: NewInsertor[TestModel](db).Values(
+				&TestModel{Id: 1, FirstName: "tom", Age: 18},
+				&TestModel{Id: 2, FirstName: "jerry", Age: 20},
+			).OnConflict().Columns(C("Id")).Update(C("Age")),
+			wantSQL: `INSERT INTO "test_model" ("id", "first_name", "age") VALUES ($1, $2, $3), ($4, $5, $6) ` +
+				`ON CONFLICT("id") DO UPDATE SET "age" = excluded."age";`,
+			wantArgs: []any{int64(1), "tom", 18, int64(2), "jerry", 20},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.builder.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSQL, q.SQL)
+			assert.Equal(t, tc.wantArgs, q.Args)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaPolicy chooses the read replica a query is sent to.
+type ReplicaPolicy interface {
+	// Pick returns the index of the replica to query, n is always positive.
+	Pick(ctx context.Context, n int) int
+	// Done reports the outcome of a query sent to replica idx.
+	Done(idx int, elapsed time.Duration, err error)
+}
+
+type primaryKey struct{}
+
+// ForcePrimary marks ctx so that reads go to the primary, typically right
+// after a write whose result must be visible.
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func isPrimaryForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryKey{}).(bool)
+	return forced
+}
+
+// WithReplicas registers read replicas. Selector queries issued on the DB
+// are routed to them, everything else and all Transaction traffic stays on
+// the primary. The policy defaults to RoundRobin.
+//
+// The replicas lag behind the primary: a read right after a write may miss
+// it, unless its context is marked with ForcePrimary or it runs in the
+// Transaction of the write.
+func WithReplicas(replicas ...*sql.DB) DBOptions {
+	return func(db *DB) {
+		db.replicas = append(db.replicas, replicas...)
+		if db.policy == nil {
+			db.policy = RoundRobin()
+		}
+	}
+}
+
+// WithReplicaPolicy sets the policy choosing the replicas, a nil policy
+// stands for RoundRobin.
+func WithReplicaPolicy(policy ReplicaPolicy) DBOptions {
+	return func(db *DB) {
+		if policy == nil {
+			policy = RoundRobin()
+		}
+		db.policy = policy
+	}
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func RoundRobin() ReplicaPolicy {
+	return &roundRobin{}
+}
+
+func (r *roundRobin) Pick(ctx context.Context, n int) int {
+	return int((r.next.Add(1) - 1) % uint64(n))
+}
+
+func (r *roundRobin) Done(idx int, elapsed time.Duration, err error) {}
+
+type random struct{}
+
+func Random() ReplicaPolicy {
+	return random{}
+}
+
+func (random) Pick(ctx context.Context, n int) int {
+	return rand.IntN(n)
+}
+
+func (random) Done(idx int, elapsed time.Duration, err error) {}
+
+// leastLatency keeps an exponentially weighted moving average of the
+// latency of each replica and picks the lowest. Replicas that have not been
+// measured yet are picked first, failed queries count as errPenalty.
+type leastLatency struct {
+	mu         sync.Mutex
+	avg        []time.Duration
+	errPenalty time.Duration
+}
+
+func LeastLatency(errPenalty time.Duration) ReplicaPolicy {
+	return &leastLatency{errPenalty: errPenalty}
+}
+
+func (l *leastLatency) Pick(ctx context.Context, n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.avg) < n {
+		l.avg = append(l.avg, make([]time.Duration, n-len(l.avg))...)
+	}
+	best := 0
+	for i := 1; i < n; i++ {
+		if l.avg[i] < l.avg[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+func (l *leastLatency) Done(idx int, elapsed time.Duration, err error) {
+	if err != nil && elapsed < l.errPenalty {
+		elapsed = l.errPenalty
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if idx >= len(l.avg) {
+		return
+	}
+	if l.avg[idx] == 0 {
+		l.avg[idx] = elapsed
+		return
+	}
+	l.avg[idx] = (l.avg[idx]*4 + elapsed) / 5
+}
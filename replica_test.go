@@ -0,0 +1,109 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDB_ReplicaRouting(t *testing.T) {
+	type TestModel struct {
+		ID   int64
+		Name string
+	}
+	primaryDB, primary, err := sqlmock.New()
+	require.NoError(t, err)
+	replicaDBs := make([]*sql.DB, 0, 2)
+	replicas := make([]sqlmock.Sqlmock, 0, 2)
+	for i := 0; i < 2; i++ {
+		rdb, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		replicaDBs = append(replicaDBs, rdb)
+		replicas = append(replicas, mock)
+	}
+	db := OpenDB(primaryDB, WithDialect(MySQLDialect), WithReplicas(replicaDBs...))
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "wang")
+	}
+
+	// round robin over both replicas
+	replicas[0].ExpectQuery("SELECT").WillReturnRows(rows())
+	replicas[1].ExpectQuery("SELECT").WillReturnRows(rows())
+	replicas[0].ExpectQuery("SELECT").WillReturnRows(rows())
+	for i := 0; i < 3; i++ {
+		_, err = NewSelector[TestModel](db).Get(&middleware.Context{Ctx: context.Background()})
+		require.NoError(t, err)
+	}
+
+	// writes, forced reads and transactions stay on the primary
+	primary.ExpectExec("INSERT").WillReturnResult(sqlmock.NewResult(1, 1))
+	primary.ExpectQuery("SELECT").WillReturnRows(rows())
+	primary.ExpectBegin()
+	primary.ExpectQuery("SELECT").WillReturnRows(rows())
+	primary.ExpectCommit()
+
+	res := NewInsertor[TestModel](db).Values(&TestModel{ID: 1, Name: "wang"}).
+		Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+	_, err = NewSelector[TestModel](db).Get(&middleware.Context{Ctx: ForcePrimary(context.Background())})
+	require.NoError(t, err)
+	err = db.DoTx(context.Background(), func(ctx context.Context, tx *Transaction) error {
+		_, err := NewSelector[TestModel](tx).Get(&middleware.Context{Ctx: ctx})
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	for _, mock := range replicas {
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestDB_NilReplicaPolicy(t *testing.T) {
+	type TestModel struct {
+		ID int64
+	}
+	primaryDB, primary, err := sqlmock.New()
+	require.NoError(t, err)
+	replicaDB, replica, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(primaryDB, WithDialect(MySQLDialect), WithReplicas(replicaDB), WithReplicaPolicy(nil))
+
+	replica.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, err = NewSelector[TestModel](db).Get(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestReplicaPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	rr := RoundRobin()
+	picks := make([]int, 0, 4)
+	for i := 0; i < 4; i++ {
+		picks = append(picks, rr.Pick(ctx, 3))
+	}
+	assert.Equal(t, []int{0, 1, 2, 0}, picks)
+
+	r := Random()
+	for i := 0; i < 16; i++ {
+		idx := r.Pick(ctx, 3)
+		assert.True(t, idx >= 0 && idx < 3)
+	}
+
+	ll := LeastLatency(time.Second)
+	assert.Equal(t, 0, ll.Pick(ctx, 3))
+	ll.Done(0, 30*time.Millisecond, nil)
+	assert.Equal(t, 1, ll.Pick(ctx, 3))
+	ll.Done(1, 10*time.Millisecond, nil)
+	assert.Equal(t, 2, ll.Pick(ctx, 3))
+	ll.Done(2, time.Millisecond, errors.New("down"))
+	assert.Equal(t, 1, ll.Pick(ctx, 3))
+}
@@ -0,0 +1,76 @@
+package go_orm
+
+import (
+	"context"
+	"maps"
+)
+
+// DefaultScope returns the predicate ANDed to the WHERE of every Selector,
+// Updater and Deletor of the model it is registered for. Compiled statements
+// call it once, with context.Background().
+type DefaultScope func(ctx context.Context) Predicate
+
+// RegisterDefaultScope registers scope as the default scope name of T,
+// replacing the previous one. It is shared by every session of db.
+func RegisterDefaultScope[T any](db *DB, name string, scope DefaultScope) error {
+	return db.registry.RegisterScope(new(T), name, scope)
+}
+
+// scopeSkip lists the default scopes disabled on a statement, see Unscoped.
+type scopeSkip struct {
+	all   bool
+	names map[string]struct{}
+}
+
+func (s *scopeSkip) add(names []string) {
+	if len(names) == 0 {
+		s.all = true
+		return
+	}
+	if s.names == nil {
+		s.names = make(map[string]struct{}, len(names))
+	}
+	for _, name := range names {
+		s.names[name] = struct{}{}
+	}
+}
+
+func (s scopeSkip) clone() scopeSkip {
+	s.names = maps.Clone(s.names)
+	return s
+}
+
+func (s scopeSkip) skips(name string) bool {
+	if s.all {
+		return true
+	}
+	_, ok := s.names[name]
+	return ok
+}
+
+// defaultScoped ANDs the default scopes of entity not disabled by skip to
+// the predicates.
+func defaultScoped(ctx context.Context, c core, entity any, skip scopeSkip, ps []Predicate) []Predicate {
+	if skip.all {
+		return ps
+	}
+	scopes := c.registry.Scopes(entity)
+	if len(scopes) == 0 {
+		return ps
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	res := make([]Predicate, len(ps), len(ps)+len(scopes))
+	copy(res, ps)
+	for _, sc := range scopes {
+		scope, ok := sc.Value.(DefaultScope)
+		if !ok || skip.skips(sc.Name) {
+			continue
+		}
+		if p := scope(ctx); p.left != nil || p.right != nil {
+			res = append(res, p)
+		}
+	}
+	return res
+}
@@ -0,0 +1,128 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type ScopedPost struct {
+	Id      int64
+	Deleted bool
+	Views   int64
+}
+
+func TestRegistry_Scopes(t *testing.T) {
+	r := &model.Registry{}
+	require.NoError(t, r.RegisterScope(&ScopedPost{}, "a", 1))
+	require.NoError(t, r.RegisterScope(ScopedPost{}, "b", 2))
+	require.NoError(t, r.RegisterScope(&ScopedPost{}, "a", 3))
+	assert.Equal(t, []model.Scope{{Name: "b", Value: 2}, {Name: "a", Value: 3}}, r.Scopes(&ScopedPost{}))
+	assert.Nil(t, r.Scopes(&TenantItem{}))
+}
+
+func TestScopes(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	require.NoError(t, RegisterDefaultScope[ScopedPost](db, "alive", func(ctx context.Context) Predicate {
+		return C("Deleted").Eq(false)
+	}))
+	require.NoError(t, RegisterDefaultScope[ScopedPost](db, "empty", func(ctx context.Context) Predicate {
+		return Predicate{}
+	}))
+	popular := func(s *Selector[ScopedPost]) *Selector[ScopedPost] {
+		return s.AndWhere(C("Views").GT(100))
+	}
+	paged := func(page int64) func(s *Selector[ScopedPost]) *Selector[ScopedPost] {
+		return func(s *Selector[ScopedPost]) *Selector[ScopedPost] {
+			return s.Limit(10).Offset((page - 1) * 10)
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		builder   Builder
+		wantQuery *Query
+	}{
+		{
+			name:    "default scope",
+			builder: NewSelector[ScopedPost](db),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `scoped_post` WHERE `deleted` = ?;",
+				Args: []any{false},
+			},
+		},
+		{
+			name:    "scopes",
+			builder: NewSelector[ScopedPost](db).Where(C("Id").GT(1)).Scopes(popular, paged(2)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `scoped_post` WHERE ((`id` > ?) AND (`views` > ?)) AND (`deleted` = ?) LIMIT ? OFFSET ?;",
+				Args: []any{1, 100, false, int64(10), int64(10)},
+			},
+		},
+		{
+			name:    "unscoped by name",
+			builder: NewSelector[ScopedPost](db).Unscoped("alive"),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `scoped_post`;",
+				Args: []any{},
+			},
+		},
+		{
+			name:    "unscoped other name",
+			builder: NewSelector[ScopedPost](db).Unscoped("other"),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `scoped_post` WHERE `deleted` = ?;",
+				Args: []any{false},
+			},
+		},
+		{
+			name: "update",
+			builder: NewUpdater[ScopedPost](db).Set(Assign("Views", 0)).
+				Scopes(func(u *Updater[ScopedPost]) *Updater[ScopedPost] {
+					return u.AndWhere(C("Id").Eq(1))
+				}),
+			wantQuery: &Query{
+				SQL:  "UPDATE `scoped_post` SET `views` = ? WHERE (`id` = ?) AND (`deleted` = ?);",
+				Args: []any{0, 1, false},
+			},
+		},
+		{
+			name:    "update unscoped",
+			builder: NewUpdater[ScopedPost](db).Set(Assign("Views", 0)).Unscoped(),
+			wantQuery: &Query{
+				SQL:  "UPDATE `scoped_post` SET `views` = ?;",
+				Args: []any{0},
+			},
+		},
+		{
+			name: "delete",
+			builder: NewDeletor[ScopedPost](db).Scopes(func(d *Deletor[ScopedPost]) *Deletor[ScopedPost] {
+				return d.AndWhere(C("Id").Eq(1))
+			}),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `scoped_post` WHERE (`id` = ?) AND (`deleted` = ?)",
+				Args: []any{1, false},
+			},
+		},
+		{
+			name:    "delete unscoped",
+			builder: NewDeletor[ScopedPost](db).Unscoped(),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `scoped_post`",
+				Args: []any{},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.builder.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
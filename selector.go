@@ -1,8 +1,13 @@
 package go_orm
 
 import (
+	"context"
 	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/model"
+	"reflect"
+	"slices"
+	"sort"
 )
 
 var _ Builder = &Selector[any]{}
@@ -12,13 +17,16 @@ type Selector[T any] struct {
 	where       []Predicate
 	selectables []Selectable
 	core        core
-	sess        session
+	sess        Session
 	builder     *builder
 	groupExpr   []Expression
 	having      []Predicate
 	order       []OrderBy
 	limit       int64
 	offset      int64
+	shardTable  string
+	opts        queryOptions
+	skip        scopeSkip
 }
 type OrderBy struct {
 	col   Column
@@ -42,7 +50,7 @@ type Selectable interface {
 	selectable()
 }
 
-func NewSelector[T any](sess session) *Selector[T] {
+func NewSelector[T any](sess Session) *Selector[T] {
 	c := sess.getCore()
 	return &Selector[T]{
 		core:      c,
@@ -54,26 +62,86 @@ func NewSelector[T any](sess session) *Selector[T] {
 	}
 }
 
-func (s *Selector[T]) Build(ctx *middleware.Context) error {
+// ToSQL returns the statement with its args interpolated, for debugging.
+// It is built as for context.Background(), see Render.
+func (s *Selector[T]) ToSQL() (string, error) {
+	return toSQL(s, s.core.dialect)
+}
+
+// Build builds the statement as for context.Background(), see BuildContext.
+func (s *Selector[T]) Build() (*Query, error) {
+	return s.BuildContext(context.Background())
+}
+
+// BuildContext builds the statement for ctx without running it, s is left
+// untouched.
+func (s *Selector[T]) BuildContext(ctx context.Context) (*Query, error) {
+	c := *s
+	return buildQuery(ctx, &c)
+}
+
+// Clone returns a copy of s which is modified and run independently of s,
+// e.g. to branch a base query shared by several goroutines.
+func (s *Selector[T]) Clone() *Selector[T] {
+	c := *s
+	c.where = slices.Clone(s.where)
+	c.selectables = slices.Clone(s.selectables)
+	c.groupExpr = slices.Clone(s.groupExpr)
+	c.having = slices.Clone(s.having)
+	c.order = slices.Clone(s.order)
+	c.opts = s.opts.clone()
+	c.skip = s.skip.clone()
+	c.builder, c.shardTable = nil, ""
+	return &c
+}
+
+func (s *Selector[T]) build(ctx *middleware.Context) error {
 	m, err := s.core.registry.Get(new(T))
 	if err != nil {
 		return err
 	}
 	s.builder = NewBuilder(m, s.core.dialect)
+	s.builder.table = s.shardTable
+	s.builder.buildComment(s.opts.comment)
 	err = s.buildSelectables()
 	if err != nil {
 		return err
 	}
 
-	err = s.buildTableReference(s.table)
+	table := s.table
+	if table == nil {
+		// no From, the table of the model is read
+		table = TableOf(new(T))
+	}
+	err = s.buildTableReference(ctx.Ctx, table)
+	if err != nil {
+		return err
+	}
+	// the tenant of the first table goes to WHERE, the ones of the joined
+	// tables to their ON clause
+	first, err := s.leftmostModel(table)
 	if err != nil {
 		return err
 	}
-	if len(s.where) > 0 {
+	_, single := table.(Table)
+	where := defaultScoped(ctx.Ctx, s.core, new(T), s.skip, s.where)
+	where, err = scoped(ctx.Ctx, first, !single, where)
+	if err != nil {
+		return err
+	}
+	s.builder.stmt = Statement{
+		Kind:   middleware.KindSelect,
+		Model:  m,
+		Limit:  s.limit,
+		Offset: s.offset,
+		where:  where,
+		order:  s.order,
+	}
+	if len(where) > 0 {
 		s.builder.buildString(" WHERE ")
-		p := s.where[0]
-		for i := 1; i < len(s.where); i++ {
-			p = p.And(s.where[i])
+		p := where[0]
+		for i := 1; i < len(where); i++ {
+			p = p.And(where[i])
 		}
 		err = s.builder.buildExpression(p, ClauseWhere)
 		if err != nil {
@@ -91,6 +159,10 @@ func (s *Selector[T]) Build(ctx *middleware.Context) error {
 				if err := s.builder.buildColumn(exp); err != nil {
 					return err
 				}
+			case columnar:
+				if err := s.builder.buildColumn(exp.Column()); err != nil {
+					return err
+				}
 			case RawExpression:
 				s.builder.buildString(exp.expression)
 				s.builder.addArgs(exp.args...)
@@ -132,8 +204,14 @@ func (s *Selector[T]) Build(ctx *middleware.Context) error {
 		s.builder.addArgs(s.offset)
 	}
 	s.builder.buildByte(';')
+	ctx.Model = s.builder.m
+	ctx.Kind = middleware.KindSelect
+	ctx.Tables = s.builder.tables
+	ctx.HasWhere = len(s.where) > 0
+	ctx.HasLimit = s.limit > 0
 	ctx.SetStatement(s.builder.getSQL())
 	ctx.SetArgs(s.builder.getArgs())
+	ctx.ArgColumns = s.builder.getArgColumns()
 	return nil
 }
 
@@ -142,16 +220,49 @@ func (s *Selector[T]) Select(selectables ...Selectable) *Selector[T] {
 	return s
 }
 
+// From sets the tables read, the table of T when From is not called.
 func (s *Selector[T]) From(table TableReference) *Selector[T] {
 	s.table = table
 	return s
 }
 
+// Scopes applies scopes to the query. A scope filtering the rows calls
+// AndWhere, Where would replace the caller's predicates.
+func (s *Selector[T]) Scopes(scopes ...func(*Selector[T]) *Selector[T]) *Selector[T] {
+	for _, scope := range scopes {
+		s = scope(s)
+	}
+	return s
+}
+
+// Unscoped disables the default scopes named names, or all of them when
+// none is given.
+func (s *Selector[T]) Unscoped(names ...string) *Selector[T] {
+	s.skip.add(names)
+	return s
+}
+
+// With sets options and middlewares for this statement only.
+func (s *Selector[T]) With(opts ...QueryOption) *Selector[T] {
+	s.opts.apply(opts)
+	return s
+}
+
+// Where sets the predicates of the WHERE clause, replacing those of the
+// previous calls, see AndWhere.
 func (s *Selector[T]) Where(p ...Predicate) *Selector[T] {
 	s.where = p
 	return s
 }
 
+// AndWhere adds predicates to the WHERE clause, combined with AND with those
+// set before.
+func (s *Selector[T]) AndWhere(p ...Predicate) *Selector[T] {
+	// the predicates given to Where may be the caller's slice
+	s.where = append(slices.Clip(s.where), p...)
+	return s
+}
+
 func (s *Selector[T]) GroupBy(expr ...Expression) *Selector[T] {
 	s.groupExpr = append(s.groupExpr, expr...)
 	return s
@@ -176,11 +287,15 @@ func (s *Selector[T]) Offset(offset int64) *Selector[T] {
 	return s
 }
 func (s *Selector[T]) Get(ctx *middleware.Context) (*T, error) {
-	root := s.handlerOne
-	for i := len(s.core.mdls) - 1; i >= 0; i-- {
-		root = s.core.mdls[i](root)
+	// run on a copy, s may be shared by several goroutines
+	c := *s
+	s = &c
+	ctx.Type = middleware.OpQuery
+	ctx.Single = true
+	if err := s.build(ctx); err != nil {
+		return nil, err
 	}
-	res := root(ctx)
+	res := s.core.run(ctx, s.opts, s.handlerOne)
 	if res.Err != nil {
 		return nil, res.Err
 	}
@@ -189,24 +304,27 @@ func (s *Selector[T]) Get(ctx *middleware.Context) (*T, error) {
 
 var _ middleware.Handler = (&Selector[any]{}).handlerOne
 
+// handlerOne expects ctx to hold the statement built by Get.
 func (s *Selector[T]) handlerOne(ctx *middleware.Context) *middleware.Result {
-	ctx.Type = middleware.OpQuery
-	err := s.Build(ctx)
-	if err != nil {
-		return &middleware.Result{
-			Res: nil,
-			Err: err,
-		}
+	if _, ok := shardingRuleOf[T](); ok {
+		return s.handlerShardedOne(ctx)
 	}
+	return s.fetchOne(ctx)
+}
 
+// fetchOne runs the statement held by ctx and scans the first row.
+func (s *Selector[T]) fetchOne(ctx *middleware.Context) *middleware.Result {
+	if ev, ok := evaluatorOf(s.sess); ok {
+		return s.evaluateOne(ctx, ev)
+	}
 	rows, err := s.sess.queryContext(ctx.Ctx, ctx.Statement, ctx.Args...)
-	defer rows.Close()
 	if err != nil {
 		return &middleware.Result{
 			Res: nil,
 			Err: err,
 		}
 	}
+	defer rows.Close()
 	if !rows.Next() {
 		return &middleware.Result{
 			Res: nil,
@@ -214,73 +332,324 @@ func (s *Selector[T]) handlerOne(ctx *middleware.Context) *middleware.Result {
 		}
 	}
 
-	t := new(T)
-	uac := NewUnsafeAccessor(s.builder.m)
+	cols, err := rows.Columns()
 	if err != nil {
 		return &middleware.Result{
 			Res: nil,
 			Err: err,
 		}
 	}
-	uac.Access(t)
-	err = uac.Set(rows)
+	t := new(T)
+	err = scanRow(s.core.accessor(s.builder.m), t, rows, cols)
 	if err != nil {
 		return &middleware.Result{
 			Res: nil,
 			Err: err,
 		}
 	}
+	// hooks may query on the same connection, release it first
+	_ = rows.Close()
+	if err = afterFind(ctx.Ctx, s.sess, t); err != nil {
+		return &middleware.Result{
+			Res: nil,
+			Err: err,
+		}
+	}
 	return &middleware.Result{
 		Res: t,
 		Err: nil,
 	}
 }
 
+func (s *Selector[T]) handlerShardedOne(ctx *middleware.Context) *middleware.Result {
+	if s.limit == 0 {
+		s.limit = 1
+		defer func() {
+			s.limit = 0
+		}()
+	}
+	res := s.handlerMulti(ctx)
+	if res.Err != nil {
+		return res
+	}
+	if multi := res.Res.([]*T); len(multi) > 0 {
+		return &middleware.Result{
+			Res: multi[0],
+			Err: nil,
+		}
+	}
+	return &middleware.Result{
+		Res: nil,
+		Err: errs.ErrNoRecord,
+	}
+}
+
 var _ middleware.Handler = (&Selector[any]{}).handlerMulti
 
+// handlerMulti expects ctx to hold the statement built by GetMulti.
 func (s *Selector[T]) handlerMulti(ctx *middleware.Context) *middleware.Result {
-	ctx.Type = middleware.OpQuery
-	err := s.Build(ctx)
+	var (
+		res []*T
+		err error
+	)
+	if rule, ok := shardingRuleOf[T](); ok {
+		res, err = s.queryShards(ctx, rule)
+	} else {
+		res, err = s.fetch(ctx, s.sess)
+	}
+	return s.found(ctx, res, err)
+}
+
+// found runs the AfterFind hooks on the rows fetched by handlerMulti.
+func (s *Selector[T]) found(ctx *middleware.Context, res []*T, err error) *middleware.Result {
 	if err != nil {
 		return &middleware.Result{
 			Res: nil,
 			Err: err,
 		}
 	}
+	for _, t := range res {
+		if err = afterFind(ctx.Ctx, s.sess, t); err != nil {
+			return &middleware.Result{
+				Res: nil,
+				Err: err,
+			}
+		}
+	}
+	return &middleware.Result{
+		Res: res,
+		Err: nil,
+	}
+}
 
-	rows, err := s.sess.queryContext(ctx.Ctx, ctx.Statement, ctx.Args...)
+func (s *Selector[T]) query(ctx *middleware.Context, sess session) ([]*T, error) {
+	err := s.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.fetch(ctx, sess)
+}
+
+// fetch runs the statement held by ctx on sess and scans every row.
+func (s *Selector[T]) fetch(ctx *middleware.Context, sess session) ([]*T, error) {
+	if ev, ok := evaluatorOf(sess); ok {
+		return s.evaluate(ctx, ev)
+	}
+	rows, err := sess.queryContext(ctx.Ctx, ctx.Statement, ctx.Args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
+	cols, err := rows.Columns()
 	if err != nil {
-		return &middleware.Result{
-			Res: nil,
-			Err: err,
-		}
+		return nil, err
 	}
-	uac := NewUnsafeAccessor(s.builder.m)
+	uac := s.core.accessor(s.builder.m)
 	res := make([]*T, 0, 32)
 	for rows.Next() {
 		t := new(T)
-		uac.Access(t)
-		err = uac.Set(rows)
+		err = scanRow(uac, t, rows, cols)
 		if err != nil {
-			return &middleware.Result{
-				Res: nil,
-				Err: err,
-			}
+			return nil, err
 		}
 		res = append(res, t)
 	}
+	return res, rows.Err()
+}
+
+func (s *Selector[T]) evaluateOne(ctx *middleware.Context, ev Evaluator) *middleware.Result {
+	res, err := s.evaluate(ctx, ev)
+	if err == nil && len(res) == 0 {
+		err = errs.ErrNoRecord
+	}
+	if err != nil {
+		return &middleware.Result{
+			Res: nil,
+			Err: err,
+		}
+	}
+	if err = afterFind(ctx.Ctx, s.sess, res[0]); err != nil {
+		return &middleware.Result{
+			Res: nil,
+			Err: err,
+		}
+	}
 	return &middleware.Result{
-		Res: res,
+		Res: res[0],
 		Err: nil,
 	}
 }
+
+// evaluate runs the statement of the last Build on ev. Only the columns
+// selected with Select are kept, C("*") keeps them all.
+func (s *Selector[T]) evaluate(ctx *middleware.Context, ev Evaluator) ([]*T, error) {
+	if _, ok := s.table.(Table); (s.table != nil && !ok) || len(s.groupExpr) > 0 || len(s.having) > 0 {
+		return nil, errs.ErrUnsupported
+	}
+	fields := make([]*model.FieldInfo, 0, len(s.selectables))
+	for _, sel := range s.selectables {
+		col, ok := sel.(Expression)
+		if ok {
+			var c Column
+			c, ok = columnOf(col)
+			if c.name == "*" {
+				fields = append(fields, s.builder.m.Fields...)
+				continue
+			}
+			fd, known := s.builder.m.GoMap[c.name]
+			if ok && !known {
+				return nil, errs.ErrUnknownField
+			}
+			fields = append(fields, fd)
+		}
+		if !ok {
+			return nil, errs.ErrUnsupported
+		}
+	}
+	rows, err := ev.Query(ctx.Ctx, &s.builder.stmt)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*T, 0, len(rows))
+	for _, row := range rows {
+		t, ok := row.(*T)
+		if !ok {
+			return nil, errs.ErrUnsupportedType
+		}
+		if len(fields) > 0 {
+			src, dst := reflect.ValueOf(t).Elem(), reflect.ValueOf(new(T)).Elem()
+			for _, fd := range fields {
+				fd.Settable(dst).Set(fd.Value(src))
+			}
+			t = dst.Addr().Interface().(*T)
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// queryShards runs the query on every destination of the sharding rule and
+// merges the rows. When it fans out, each shard is asked for LIMIT + OFFSET
+// rows and ORDER BY, OFFSET and LIMIT are applied again on the merged rows,
+// the ORDER BY columns have to be selected; GROUP BY and aggregates are not
+// merged.
+func (s *Selector[T]) queryShards(ctx *middleware.Context, rule ShardingRule) ([]*T, error) {
+	dsts, err := shardDsts(rule, s.where)
+	if err != nil {
+		return nil, err
+	}
+	if len(dsts) > 1 && !s.orderSelected() {
+		return nil, errs.ErrOrderNotSelected
+	}
+	limit, offset := s.limit, s.offset
+	defer func() {
+		s.limit, s.offset, s.shardTable = limit, offset, ""
+	}()
+	if len(dsts) > 1 {
+		if limit > 0 {
+			s.limit = limit + offset
+		}
+		s.offset = 0
+	}
+	res := make([]*T, 0, 32)
+	// the statements on the shards are built on a copy, ctx keeps the one
+	// on the logical table
+	shardCtx := *ctx
+	for _, dst := range dsts {
+		sess, err := s.sess.shard(dst.DB)
+		if err != nil {
+			return nil, err
+		}
+		s.shardTable = dst.Table
+		part, err := s.query(&shardCtx, sess)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, part...)
+	}
+	if len(dsts) == 1 {
+		return res, nil
+	}
+	if err = s.sortMerged(res); err != nil {
+		return nil, err
+	}
+	if offset >= int64(len(res)) {
+		return res[:0], nil
+	}
+	res = res[offset:]
+	if limit > 0 && limit < int64(len(res)) {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// orderSelected tells whether the ORDER BY columns are read, the merged rows
+// are sorted by their fields.
+func (s *Selector[T]) orderSelected() bool {
+	if len(s.selectables) == 0 {
+		return true
+	}
+	selected := make(map[string]bool, len(s.selectables))
+	for _, sel := range s.selectables {
+		if exp, ok := sel.(Expression); ok {
+			if col, ok := columnOf(exp); ok {
+				selected[col.name] = true
+			}
+		}
+	}
+	if selected["*"] {
+		return true
+	}
+	for _, o := range s.order {
+		if !selected[o.col.name] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Selector[T]) sortMerged(res []*T) error {
+	if len(s.order) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(s.order))
+	for _, o := range s.order {
+		fields = append(fields, o.col.name)
+	}
+	uac := s.core.accessor(s.builder.m)
+	keys := make(map[*T][]any, len(res))
+	for _, t := range res {
+		vals, err := fieldValues(uac, t, fields)
+		if err != nil {
+			return err
+		}
+		keys[t] = vals
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		ki, kj := keys[res[i]], keys[res[j]]
+		for idx, o := range s.order {
+			c := compareValues(ki[idx], kj[idx])
+			if c == 0 {
+				continue
+			}
+			if o.order == "DESC" {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
 func (s *Selector[T]) GetMulti(ctx *middleware.Context) ([]*T, error) {
-	root := s.handlerMulti
-	for i := len(s.core.mdls) - 1; i >= 0; i-- {
-		root = s.core.mdls[i](root)
+	// run on a copy, s may be shared by several goroutines
+	c := *s
+	s = &c
+	ctx.Type = middleware.OpQuery
+	if err := s.build(ctx); err != nil {
+		return nil, err
 	}
-	res := root(ctx)
+	res := s.core.run(ctx, s.opts, s.handlerMulti)
 	if res.Err != nil {
 		return nil, res.Err
 	}
@@ -300,6 +669,10 @@ func (s *Selector[T]) buildSelectables() error {
 				if err := s.builder.buildColumn(se); err != nil {
 					return err
 				}
+			case columnar:
+				if err := s.builder.buildColumn(se.Column()); err != nil {
+					return err
+				}
 			case Aggregate:
 				if err := s.builder.buildAggregate(se); err != nil {
 					return err
@@ -317,20 +690,20 @@ func (s *Selector[T]) buildSelectables() error {
 	return nil
 }
 
-func (s *Selector[T]) buildTableReference(table TableReference) error {
+func (s *Selector[T]) buildTableReference(ctx context.Context, table TableReference) error {
 	switch t := table.(type) {
 	case Table:
 		m, err := s.core.registry.Get(t.entity)
 		if err != nil {
 			return err
 		}
-		s.builder.quote(m.TableName)
+		s.builder.buildTable(m)
 	case Join:
 		_, ok := t.left.(join)
 		if ok {
 			s.builder.buildByte('(')
 		}
-		err := s.buildTableReference(t.left)
+		err := s.buildTableReference(ctx, t.left)
 		if err != nil {
 			return err
 		}
@@ -344,22 +717,34 @@ func (s *Selector[T]) buildTableReference(table TableReference) error {
 		if ok {
 			s.builder.buildByte('(')
 		}
-		err = s.buildTableReference(t.right)
+		err = s.buildTableReference(ctx, t.right)
+		if err != nil {
+			return err
+		}
+		right, err := s.leftmostModel(t.right)
 		if err != nil {
 			return err
 		}
-		if len(t.on) > 0 {
+		on, err := scoped(ctx, right, true, t.on)
+		if err != nil {
+			return err
+		}
+		if len(on) > len(t.on) && len(t.using) > 0 {
+			// USING cannot be combined with the tenant predicate
+			return errs.ErrUnsupported
+		}
+		if len(on) > 0 {
 			s.builder.buildString(" ON ")
-			p := t.on[0]
-			for i := 1; i < len(t.on); i++ {
-				p = p.And(t.on[i])
+			p := on[0]
+			for i := 1; i < len(on); i++ {
+				p = p.And(on[i])
 			}
 			err = s.builder.buildExpression(p, ClauseOn)
 			if err != nil {
 				return err
 			}
 		} else if len(t.using) > 0 {
-			s.builder.buildByte('(')
+			s.builder.buildString(" USING (")
 			for i, col := range t.using {
 				if i > 0 {
 					s.builder.buildString(", ")
@@ -375,7 +760,7 @@ func (s *Selector[T]) buildTableReference(table TableReference) error {
 			s.builder.buildByte(')')
 		}
 	case JoinBuilder:
-		err := s.buildTableReference(t.toJoin())
+		err := s.buildTableReference(ctx, t.toJoin())
 		if err != nil {
 			return err
 		}
@@ -384,3 +769,16 @@ func (s *Selector[T]) buildTableReference(table TableReference) error {
 	}
 	return nil
 }
+
+// leftmostModel returns the model of the first table of ref.
+func (s *Selector[T]) leftmostModel(ref TableReference) (*model.Model, error) {
+	switch t := ref.(type) {
+	case Table:
+		return s.core.registry.Get(t.entity)
+	case Join:
+		return s.leftmostModel(t.left)
+	case JoinBuilder:
+		return s.leftmostModel(t.left)
+	}
+	return nil, errs.ErrUnsupportedType
+}
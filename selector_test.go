@@ -9,6 +9,7 @@ import (
 	"github.com/kisara71/go-orm/middleware/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
 )
 
@@ -44,6 +45,23 @@ func TestSelector(t *testing.T) {
 				Args: []any{"hha"},
 			},
 			wantErr: nil,
+		}, {
+			// the last call replaces the predicates
+			name:    "where twice",
+			builder: NewSelector[TestModel](db).Where(C("Name").Eq("hha")).Where(C("Age").Eq(111)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` = ?;",
+				Args: []any{111},
+			},
+			wantErr: nil,
+		}, {
+			name:    "and where",
+			builder: NewSelector[TestModel](db).Where(C("Name").Eq("hha")).AndWhere(C("Age").Eq(111)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`name` = ?) AND (`age` = ?);",
+				Args: []any{"hha", 111},
+			},
+			wantErr: nil,
 		}, {
 			name:    "where not",
 			builder: NewSelector[TestModel](db).Where(Not(C("Age").Eq(111))),
@@ -268,16 +286,12 @@ func TestSelector(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := &middleware.Context{Ctx: context.Background()}
-			err := tc.builder.Build(ctx)
+			q, err := tc.builder.Build()
 			assert.Equal(t, err, tc.wantErr)
 			if err != nil {
 				return
 			}
-			assert.Equal(t, tc.wantQuery, &Query{
-				SQL:  ctx.Statement,
-				Args: ctx.Args,
-			})
+			assert.Equal(t, tc.wantQuery, q)
 		})
 	}
 }
@@ -353,6 +367,14 @@ func TestSelector_Get(t *testing.T) {
 			wantRes:  nil,
 			selector: NewSelector[TestModel](db).Where(C("ID").Eq(1)),
 		},
+		{
+			name: "query error",
+			expect: func(mk sqlmock.Sqlmock) {
+				mk.ExpectQuery("SELECT \\* FROM `test_model` WHERE .*;").WillReturnError(sql.ErrConnDone)
+			},
+			wantErr:  sql.ErrConnDone,
+			selector: NewSelector[TestModel](db).Where(C("ID").Eq(1)),
+		},
 		{
 			name: "build error",
 			expect: func(mk sqlmock.Sqlmock) {
@@ -433,6 +455,14 @@ func TestSelector_GetMulti(t *testing.T) {
 			wantRes:  nil,
 			selector: NewSelector[TestModel](db).Where(C("ID").Eq(1)),
 		},
+		{
+			name: "query error",
+			expect: func(mk sqlmock.Sqlmock) {
+				mk.ExpectQuery("SELECT \\* FROM `test_model` WHERE .*;").WillReturnError(sql.ErrConnDone)
+			},
+			wantErr:  sql.ErrConnDone,
+			selector: NewSelector[TestModel](db).Where(C("ID").Eq(1)),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -529,16 +559,130 @@ func TestSelector_BuildJoin(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := &middleware.Context{Ctx: context.Background()}
-			err := tc.builder.Build(ctx)
+			q, err := tc.builder.Build()
 			assert.NoError(t, err)
 			if err != nil {
 				return
 			}
-			assert.Equal(t, tc.wantQuery, &Query{
-				SQL:  ctx.Statement,
-				Args: ctx.Args,
-			})
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSelector_BuildPure(t *testing.T) {
+	type TestModel struct {
+		Id   int64
+		Name string
+	}
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	s := NewSelector[TestModel](db).Where(C("Id").Eq(1))
+	q1, err := s.Build()
+	require.NoError(t, err)
+	q2, err := s.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT * FROM `test_model` WHERE `id` = ?;",
+		Args: []any{1},
+	}, q1)
+	assert.Equal(t, q1, q2)
+	assert.Nil(t, s.builder)
+}
+
+// TestSelector_DefaultTable checks that a Selector without From reads the
+// table of its model, scoped as if the model was given to From.
+func TestSelector_DefaultTable(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	testCases := []struct {
+		name      string
+		build     func() (*Query, error)
+		wantQuery *Query
+	}{
+		{
+			name: "model table",
+			build: func() (*Query, error) {
+				return NewSelector[TenantOrder](db).Where(C("Id").Eq(1)).
+					BuildContext(WithoutTenant(context.Background()))
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `tenant_order` WHERE `id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "table name",
+			build: func() (*Query, error) {
+				return NewSelector[customTable](db).Build()
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `my_custom_table`;",
+				Args: []any{},
+			},
+		},
+		{
+			name: "tenant",
+			build: func() (*Query, error) {
+				return NewSelector[TenantOrder](db).BuildContext(WithTenant(context.Background(), int64(7)))
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `tenant_order` WHERE `tenant_id` = ?;",
+				Args: []any{int64(7)},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
 		})
 	}
 }
+
+// TestSelector_Concurrent branches and runs a shared base selector from
+// several goroutines, run it with -race.
+func TestSelector_Concurrent(t *testing.T) {
+	type ConcurrentModel struct {
+		Id   int64
+		Name string
+	}
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	sqldb.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqldb.Close() })
+	_, err = sqldb.Exec(`CREATE TABLE concurrent_model (id INTEGER PRIMARY KEY, name TEXT)`)
+	require.NoError(t, err)
+	db := OpenDB(sqldb, WithDialect(SqliteDialect))
+	for i := int64(1); i <= 8; i++ {
+		res := NewInsertor[ConcurrentModel](db).Values(&ConcurrentModel{Id: i, Name: "name"}).
+			Exec(&middleware.Context{Ctx: context.Background()})
+		require.NoError(t, res.Err())
+	}
+
+	base := NewSelector[ConcurrentModel](db).Where(C("Name").Eq("name")).OrderBy(ASC("Id"))
+	var wg sync.WaitGroup
+	for i := int64(1); i <= 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := &middleware.Context{Ctx: context.Background()}
+			all, err := base.GetMulti(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, all, 8)
+			one, err := base.Clone().Where(C("Id").Eq(i)).Get(&middleware.Context{Ctx: context.Background()})
+			assert.NoError(t, err)
+			assert.Equal(t, &ConcurrentModel{Id: i, Name: "name"}, one)
+			q, err := base.Clone().Limit(i).Build()
+			assert.NoError(t, err)
+			assert.Equal(t, []any{"name", i}, q.Args)
+		}()
+	}
+	wg.Wait()
+	q, err := base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "concurrent_model" WHERE "name" = ? ORDER BY "id" ASC;`, q.SQL)
+}
@@ -0,0 +1,319 @@
+package go_orm
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Sharded is implemented by models whose rows are split across databases
+// and tables. Builders derive the destination from the shard key found in
+// an equality of the WHERE clause, or in the inserted values, and fan out
+// to every destination when the key is absent.
+//
+// A write fanning out is not atomic: the destinations are written one after
+// the other and the first failure stops it, the earlier ones keeping their
+// changes. The middlewares see the statement on the logical table once, not
+// the statement run on each destination.
+type Sharded interface {
+	ShardingRule() ShardingRule
+}
+
+type ShardingRule struct {
+	// Key is the Go field name of the shard key.
+	Key       string
+	Algorithm ShardAlgorithm
+}
+
+// ShardDst is a physical destination: an index into the databases
+// registered with WithShards and a table name.
+type ShardDst struct {
+	DB    int
+	Table string
+}
+
+type ShardAlgorithm interface {
+	Shard(key any) (ShardDst, error)
+	// All returns every destination, in a stable order.
+	All() []ShardDst
+}
+
+// WithShards registers the databases sharded models are spread over.
+// Without it every destination has to live in the primary database.
+func WithShards(dbs ...*sql.DB) DBOptions {
+	return func(db *DB) {
+		db.shards = append(db.shards, dbs...)
+	}
+}
+
+type hashSharding struct {
+	base   string
+	dbs    int
+	tables int
+}
+
+// HashSharding spreads keys over dbs databases holding tables tables each by
+// the FNV hash of their textual form. Tables are named base_0 to base_N-1
+// with N = dbs * tables, table i living in database i / tables.
+func HashSharding(base string, dbs int, tables int) ShardAlgorithm {
+	return &hashSharding{base: base, dbs: dbs, tables: tables}
+}
+
+func (h *hashSharding) Shard(key any) (ShardDst, error) {
+	f := fnv.New64a()
+	_, _ = fmt.Fprint(f, key)
+	return indexDst(h.base, h.tables, int(f.Sum64()%uint64(h.dbs*h.tables))), nil
+}
+
+func (h *hashSharding) All() []ShardDst {
+	return allDst(h.base, h.dbs, h.tables)
+}
+
+type modSharding struct {
+	base   string
+	dbs    int
+	tables int
+}
+
+// ModSharding is HashSharding for integer keys, using the key modulo the
+// number of tables instead of its hash.
+func ModSharding(base string, dbs int, tables int) ShardAlgorithm {
+	return &modSharding{base: base, dbs: dbs, tables: tables}
+}
+
+func (m *modSharding) Shard(key any) (ShardDst, error) {
+	k, err := toInt64(key)
+	if err != nil {
+		return ShardDst{}, err
+	}
+	n := k % int64(m.dbs*m.tables)
+	if n < 0 {
+		n = -n
+	}
+	return indexDst(m.base, m.tables, int(n)), nil
+}
+
+func (m *modSharding) All() []ShardDst {
+	return allDst(m.base, m.dbs, m.tables)
+}
+
+// ShardRange routes integer keys lower than Upper, and not routed by a
+// previous range, to Dst.
+type ShardRange struct {
+	Upper int64
+	Dst   ShardDst
+}
+
+type rangeSharding struct {
+	ranges []ShardRange
+}
+
+// RangeSharding routes integer keys by ranges sorted by Upper.
+func RangeSharding(ranges ...ShardRange) ShardAlgorithm {
+	rs := append([]ShardRange(nil), ranges...)
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Upper < rs[j].Upper
+	})
+	return &rangeSharding{ranges: rs}
+}
+
+func (r *rangeSharding) Shard(key any) (ShardDst, error) {
+	k, err := toInt64(key)
+	if err != nil {
+		return ShardDst{}, err
+	}
+	for _, rg := range r.ranges {
+		if k < rg.Upper {
+			return rg.Dst, nil
+		}
+	}
+	return ShardDst{}, errs.ErrNoShard
+}
+
+func (r *rangeSharding) All() []ShardDst {
+	res := make([]ShardDst, 0, len(r.ranges))
+	for _, rg := range r.ranges {
+		res = append(res, rg.Dst)
+	}
+	return res
+}
+
+func indexDst(base string, tables int, n int) ShardDst {
+	return ShardDst{DB: n / tables, Table: fmt.Sprintf("%s_%d", base, n)}
+}
+
+func allDst(base string, dbs int, tables int) []ShardDst {
+	res := make([]ShardDst, 0, dbs*tables)
+	for n := 0; n < dbs*tables; n++ {
+		res = append(res, indexDst(base, tables, n))
+	}
+	return res
+}
+
+func toInt64(key any) (int64, error) {
+	val := reflect.ValueOf(key)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(val.Uint()), nil
+	}
+	return 0, errs.ErrInvalidShardKey
+}
+
+func shardingRuleOf[T any]() (ShardingRule, bool) {
+	if s, ok := any(new(T)).(Sharded); ok {
+		return s.ShardingRule(), true
+	}
+	return ShardingRule{}, false
+}
+
+// shardDsts returns the destinations a statement filtered by where has to
+// be sent to.
+func shardDsts(rule ShardingRule, where []Predicate) ([]ShardDst, error) {
+	for _, p := range where {
+		if key, ok := shardKeyOf(rule.Key, p); ok {
+			dst, err := rule.Algorithm.Shard(key)
+			if err != nil {
+				return nil, err
+			}
+			return []ShardDst{dst}, nil
+		}
+	}
+	return rule.Algorithm.All(), nil
+}
+
+// shardKeyOf looks for key = ? in the AND chain of p. Equalities below OR
+// and NOT do not pin the destination and are ignored.
+func shardKeyOf(key string, p Predicate) (any, bool) {
+	switch p.op {
+	case opAnd:
+		for _, side := range []Expression{p.left, p.right} {
+			if sub, ok := side.(Predicate); ok {
+				if val, ok := shardKeyOf(key, sub); ok {
+					return val, true
+				}
+			}
+		}
+	case opEq:
+		col, ok := p.left.(Column)
+		if c, isTyped := p.left.(columnar); isTyped {
+			col, ok = c.Column(), true
+		}
+		arg, isArg := p.right.(Arg)
+		if ok && isArg && col.name == key {
+			return arg.val, true
+		}
+	}
+	return nil, false
+}
+
+// execShards builds the statement for each destination with build and
+// executes it on the destination's database. The statements are built on a
+// copy of ctx, which keeps the one on the logical table.
+func execShards(ctx *middleware.Context, sess session, dsts []ShardDst,
+	build func(ctx *middleware.Context, idx int, dst ShardDst) error) (sql.Result, error) {
+	res := make(shardResult, 0, len(dsts))
+	shardCtx := *ctx
+	for idx, dst := range dsts {
+		target, err := sess.shard(dst.DB)
+		if err != nil {
+			return nil, err
+		}
+		if err = build(&shardCtx, idx, dst); err != nil {
+			return nil, err
+		}
+		r, err := target.execContext(shardCtx.Ctx, shardCtx.Statement, shardCtx.Args...)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// shardResult merges the results of a statement executed on several shards.
+type shardResult []sql.Result
+
+func (s shardResult) LastInsertId() (int64, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	return s[len(s)-1].LastInsertId()
+}
+
+func (s shardResult) RowsAffected() (int64, error) {
+	var total int64
+	for _, res := range s {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// compareValues orders two field values of the same type, looking through
+// driver.Valuer implementations such as sql.NullString.
+func compareValues(a, b any) int {
+	if v, ok := a.(driver.Valuer); ok {
+		a, _ = v.Value()
+	}
+	if v, ok := b.(driver.Valuer); ok {
+		b, _ = v.Value()
+	}
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	switch av := a.(type) {
+	case time.Time:
+		return av.Compare(b.(time.Time))
+	case []byte:
+		return bytes.Compare(av, b.([]byte))
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch va.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmpOrdered(va.Int(), vb.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmpOrdered(va.Uint(), vb.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmpOrdered(va.Float(), vb.Float())
+	case reflect.String:
+		return cmpOrdered(va.String(), vb.String())
+	case reflect.Bool:
+		return cmpOrdered(boolToInt(va.Bool()), boolToInt(vb.Bool()))
+	}
+	return 0
+}
+
+func cmpOrdered[V int64 | uint64 | float64 | string | int](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
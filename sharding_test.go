@@ -0,0 +1,214 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type shardOrder struct {
+	ID     int64
+	UserID int64
+	Amount int
+}
+
+func (shardOrder) ShardingRule() ShardingRule {
+	return ShardingRule{
+		Key:       "UserID",
+		Algorithm: ModSharding("order", 2, 2),
+	}
+}
+
+func TestShardAlgorithm(t *testing.T) {
+	mod := ModSharding("order", 2, 2)
+	dst, err := mod.Shard(int64(5))
+	require.NoError(t, err)
+	assert.Equal(t, ShardDst{DB: 0, Table: "order_1"}, dst)
+	dst, err = mod.Shard(6)
+	require.NoError(t, err)
+	assert.Equal(t, ShardDst{DB: 1, Table: "order_2"}, dst)
+	_, err = mod.Shard("6")
+	assert.Equal(t, errs.ErrInvalidShardKey, err)
+	assert.Equal(t, []ShardDst{
+		{DB: 0, Table: "order_0"}, {DB: 0, Table: "order_1"},
+		{DB: 1, Table: "order_2"}, {DB: 1, Table: "order_3"},
+	}, mod.All())
+
+	hash := HashSharding("user", 2, 4)
+	first, err := hash.Shard("wang")
+	require.NoError(t, err)
+	again, err := hash.Shard("wang")
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+	assert.Contains(t, hash.All(), first)
+
+	rg := RangeSharding(
+		ShardRange{Upper: 2000, Dst: ShardDst{DB: 1, Table: "log_2"}},
+		ShardRange{Upper: 1000, Dst: ShardDst{DB: 0, Table: "log_1"}},
+	)
+	dst, err = rg.Shard(999)
+	require.NoError(t, err)
+	assert.Equal(t, ShardDst{DB: 0, Table: "log_1"}, dst)
+	dst, err = rg.Shard(1000)
+	require.NoError(t, err)
+	assert.Equal(t, ShardDst{DB: 1, Table: "log_2"}, dst)
+	_, err = rg.Shard(2000)
+	assert.Equal(t, errs.ErrNoShard, err)
+}
+
+func newShardedDB(t *testing.T) (*DB, []sqlmock.Sqlmock) {
+	primary, _, err := sqlmock.New()
+	require.NoError(t, err)
+	dbs := make([]*sql.DB, 0, 2)
+	mocks := make([]sqlmock.Sqlmock, 0, 2)
+	for i := 0; i < 2; i++ {
+		sdb, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		require.NoError(t, err)
+		dbs = append(dbs, sdb)
+		mocks = append(mocks, mock)
+	}
+	return OpenDB(primary, WithDialect(MySQLDialect), WithShards(dbs...)), mocks
+}
+
+func TestSharding_Select(t *testing.T) {
+	db, mocks := newShardedDB(t)
+	cols := []string{"id", "user_id", "amount"}
+
+	mocks[1].ExpectQuery("SELECT * FROM `order_2` WHERE (`user_id` = ?) AND (`amount` > ?);").
+		WithArgs(int64(6), 10).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, 6, 20))
+	res, err := NewSelector[shardOrder](db).Where(C("UserID").Eq(int64(6)), C("Amount").GT(10)).
+		GetMulti(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.Equal(t, []*shardOrder{{ID: 1, UserID: 6, Amount: 20}}, res)
+
+	// without the shard key every table is queried and the rows merged
+	amounts := map[string][]int{
+		"order_0": {50, 10},
+		"order_1": {40},
+		"order_2": {},
+		"order_3": {30, 20},
+	}
+	for _, dst := range ModSharding("order", 2, 2).All() {
+		rows := sqlmock.NewRows(cols)
+		for _, amount := range amounts[dst.Table] {
+			rows.AddRow(amount, 0, amount)
+		}
+		mocks[dst.DB].ExpectQuery("SELECT * FROM `" + dst.Table + "` ORDER BY `amount` DESC LIMIT ?;").
+			WithArgs(int64(3)).WillReturnRows(rows)
+	}
+	res, err = NewSelector[shardOrder](db).OrderBy(DESC("Amount")).Limit(2).Offset(1).
+		GetMulti(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	assert.Equal(t, []*shardOrder{{ID: 40, Amount: 40}, {ID: 30, Amount: 30}}, res)
+
+	// the merged rows are sorted by their fields, which have to be read
+	_, err = NewSelector[shardOrder](db).Select(C("ID")).OrderBy(DESC("Amount")).
+		GetMulti(&middleware.Context{Ctx: context.Background()})
+	assert.Equal(t, errs.ErrOrderNotSelected, err)
+
+	for _, mock := range mocks {
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestSharding_Exec(t *testing.T) {
+	db, mocks := newShardedDB(t)
+
+	mocks[0].ExpectExec("INSERT INTO `order_1` (`id`, `user_id`, `amount`) VALUES (?, ?, ?), (?, ?, ?);").
+		WithArgs(int64(1), int64(1), 10, int64(3), int64(5), 30).
+		WillReturnResult(sqlmock.NewResult(3, 2))
+	mocks[1].ExpectExec("INSERT INTO `order_2` (`id`, `user_id`, `amount`) VALUES (?, ?, ?);").
+		WithArgs(int64(2), int64(2), 20).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	res := NewInsertor[shardOrder](db).Values(
+		&shardOrder{ID: 1, UserID: 1, Amount: 10},
+		&shardOrder{ID: 2, UserID: 2, Amount: 20},
+		&shardOrder{ID: 3, UserID: 5, Amount: 30},
+	).Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+
+	mocks[0].ExpectExec("UPDATE `order_1` SET `amount` = ? WHERE `user_id` = ?;").
+		WithArgs(1, int64(5)).WillReturnResult(sqlmock.NewResult(0, 1))
+	res = NewUpdater[shardOrder](db).Set(Assign("Amount", 1)).Where(C("UserID").Eq(int64(5))).
+		Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+
+	for _, dst := range ModSharding("order", 2, 2).All() {
+		mocks[dst.DB].ExpectExec("DELETE FROM `" + dst.Table + "` WHERE `amount` > ?").
+			WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	d := NewDeletor[shardOrder](db)
+	d.Where(C("Amount").GT(100))
+	res = d.Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+	affected, err = res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), affected)
+
+	for _, mock := range mocks {
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestSharding_Middleware(t *testing.T) {
+	db, mocks := newShardedDB(t)
+	var stmts []string
+	record := WithMiddlewares(func(next middleware.Handler) middleware.Handler {
+		return func(ctx *middleware.Context) *middleware.Result {
+			res := next(ctx)
+			stmts = append(stmts, ctx.Statement)
+			return res
+		}
+	})
+
+	// the middlewares see the statement on the logical table once fanned out
+	for _, dst := range ModSharding("order", 2, 2).All() {
+		mocks[dst.DB].ExpectQuery("SELECT * FROM `" + dst.Table + "` ORDER BY `id` ASC;").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+	for _, dst := range ModSharding("order", 2, 2).All() {
+		mocks[dst.DB].ExpectExec("DELETE FROM `" + dst.Table + "` WHERE `amount` > ?").
+			WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	_, err := NewSelector[shardOrder](db).OrderBy(ASC("ID")).With(record).
+		GetMulti(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, err)
+	d := NewDeletor[shardOrder](db).With(record)
+	d.Where(C("Amount").GT(100))
+	require.NoError(t, d.Exec(&middleware.Context{Ctx: context.Background()}).Err())
+	assert.Equal(t, []string{
+		"SELECT * FROM `shard_order` ORDER BY `id` ASC;",
+		"DELETE FROM `shard_order` WHERE `amount` > ?",
+	}, stmts)
+
+	for _, mock := range mocks {
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestSharding_Transaction(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	shard, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect), WithShards(shard))
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	_, err = NewSelector[shardOrder](tx).Where(C("UserID").Eq(1)).
+		GetMulti(&middleware.Context{Ctx: context.Background()})
+	assert.Equal(t, errs.ErrShardInTx, err)
+	require.NoError(t, tx.RollBack())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
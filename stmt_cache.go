@@ -0,0 +1,153 @@
+package go_orm
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// WithStmtCache enables a per DB LRU cache of prepared statements keyed by
+// statement text, holding at most capacity statements. Evicted statements
+// are closed once the statements running them are done. Transactions re-bind
+// cached statements with Tx.StmtContext.
+func WithStmtCache(capacity int) DBOptions {
+	return func(db *DB) {
+		if capacity > 0 {
+			db.stmts = newStmtCache(capacity)
+		}
+	}
+}
+
+type StmtCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+func (s StmtCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+type stmtEntry struct {
+	key  stmtKey
+	stmt *sql.Stmt
+	// refs counts the callers holding stmt, an evicted entry is closed by
+	// the last of them
+	refs    int
+	evicted bool
+}
+
+type stmtCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[stmtKey]*list.Element
+	stats    StmtCacheStats
+}
+
+func newStmtCache(capacity int) *stmtCache {
+	return &stmtCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[stmtKey]*list.Element, capacity),
+	}
+}
+
+// prepare returns the statement of query on db, which stays open until
+// release is called. The statement may be closed afterwards: the rows and
+// the transaction statements already created from it keep it usable.
+func (c *stmtCache) prepare(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, func(), error) {
+	key := stmtKey{db: db, query: query}
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		c.stats.Hits++
+		entry := c.acquire(elem)
+		c.mu.Unlock()
+		return entry.stmt, func() { c.release(entry) }, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		// prepared concurrently, keep the cached one
+		_ = stmt.Close()
+		c.ll.MoveToFront(elem)
+		entry := c.acquire(elem)
+		return entry.stmt, func() { c.release(entry) }, nil
+	}
+	entry := &stmtEntry{key: key, stmt: stmt, refs: 1}
+	c.items[key] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		evicted := c.ll.Remove(oldest).(*stmtEntry)
+		delete(c.items, evicted.key)
+		c.stats.Evictions++
+		evicted.evicted = true
+		if evicted.refs == 0 {
+			_ = evicted.stmt.Close()
+		}
+	}
+	return stmt, func() { c.release(entry) }, nil
+}
+
+func (c *stmtCache) acquire(elem *list.Element) *stmtEntry {
+	entry := elem.Value.(*stmtEntry)
+	entry.refs++
+	return entry
+}
+
+// release gives entry back, closing it when it was evicted meanwhile.
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) snapshot() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.ll.Len()
+	return stats
+}
+
+func (c *stmtCache) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for elem := c.ll.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*stmtEntry)
+		entry.evicted = true
+		if entry.refs > 0 {
+			// closed by release
+			continue
+		}
+		if cerr := entry.stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	c.ll.Init()
+	clear(c.items)
+	return err
+}
@@ -0,0 +1,120 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestStmtCache(t *testing.T) {
+	type TestModel struct {
+		ID   int64
+		Name string
+	}
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect), WithStmtCache(1))
+	selectByID := "SELECT * FROM `test_model` WHERE `id` = ?;"
+	update := "UPDATE `test_model` SET `name` = ?;"
+
+	prepared := mock.ExpectPrepare(selectByID).WillBeClosed()
+	prepared.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	prepared.ExpectQuery().WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectPrepare(update).ExpectExec().WithArgs("wang").WillReturnResult(sqlmock.NewResult(0, 2))
+
+	for _, id := range []int{1, 2} {
+		res, err := NewSelector[TestModel](db).Where(C("ID").Eq(id)).
+			Get(&middleware.Context{Ctx: context.Background()})
+		require.NoError(t, err)
+		assert.Equal(t, int64(id), res.ID)
+	}
+	// capacity is one, preparing the update evicts and closes the select
+	res := NewUpdater[TestModel](db).Set(Assign("Name", "wang")).
+		Exec(&middleware.Context{Ctx: context.Background()})
+	require.NoError(t, res.Err())
+
+	stats := db.StmtCacheStats()
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 2, Evictions: 1, Size: 1}, stats)
+	assert.InDelta(t, 1.0/3, stats.HitRate(), 1e-9)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStmtCache_Transaction(t *testing.T) {
+	type TestModel struct {
+		ID   int64
+		Name string
+	}
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect), WithStmtCache(8))
+	update := "UPDATE `test_model` SET `name` = ?;"
+
+	mock.ExpectBegin()
+	// prepared once on the pool for the cache, then re-bound to the
+	// transaction's connection; the bound statement is reused afterwards
+	mock.ExpectPrepare(update)
+	prepared := mock.ExpectPrepare(update)
+	prepared.ExpectExec().WithArgs("wang").WillReturnResult(sqlmock.NewResult(0, 1))
+	prepared.ExpectExec().WithArgs("li").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = db.DoTx(context.Background(), func(ctx context.Context, tx *Transaction) error {
+		for _, name := range []string{"wang", "li"} {
+			res := NewUpdater[TestModel](tx).Set(Assign("Name", name)).Exec(&middleware.Context{Ctx: ctx})
+			if res.Err() != nil {
+				return res.Err()
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StmtCacheStats{Misses: 1, Size: 1}, db.StmtCacheStats())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestStmtCache_Concurrent runs more distinct statements than the cache holds
+// from several goroutines, evicting statements still in use. Run it with -race.
+func TestStmtCache_Concurrent(t *testing.T) {
+	type StmtModel struct {
+		Id   int64
+		Name string
+	}
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	sqldb.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqldb.Close() })
+	_, err = sqldb.Exec(`CREATE TABLE stmt_model (id INTEGER PRIMARY KEY, name TEXT)`)
+	require.NoError(t, err)
+	db := OpenDB(sqldb, WithDialect(SqliteDialect), WithStmtCache(1))
+	_, err = sqldb.Exec(`INSERT INTO stmt_model (id, name) VALUES (1, 'tom')`)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				// 8 distinct statements for a capacity of 1
+				query := fmt.Sprintf(`SELECT "id", "name" FROM "stmt_model" WHERE "id" = ? AND %d = %d;`, n%8, n%8)
+				res, err := RawQuery[StmtModel](db, query, 1).Get(&middleware.Context{Ctx: context.Background()})
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, &StmtModel{Id: 1, Name: "tom"}, res)
+				exec := fmt.Sprintf(`UPDATE "stmt_model" SET "name" = ? WHERE %d = %d;`, n%4, n%4)
+				assert.NoError(t, RawExec(db, exec, "tom").Exec(&middleware.Context{Ctx: context.Background()}).Err())
+			}
+		}()
+	}
+	wg.Wait()
+	stats := db.StmtCacheStats()
+	assert.Equal(t, 1, stats.Size)
+	assert.NotZero(t, stats.Evictions)
+}
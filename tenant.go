@@ -0,0 +1,127 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/model"
+	"reflect"
+)
+
+type tenantKey struct{}
+
+type noTenantKey struct{}
+
+// tenantParam is the Parameter standing for the tenant in compiled queries,
+// bound from the context on every execution.
+const tenantParam = "go-orm.tenant"
+
+// WithTenant scopes the statements run with ctx on the models having a
+// field tagged with orm:"tenant" to tenant.
+func WithTenant(ctx context.Context, tenant any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func TenantFrom(ctx context.Context) (any, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// WithoutTenant lets the statements run with ctx reach the rows of every
+// tenant, e.g. for maintenance jobs.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTenantKey{}, true)
+}
+
+// tenantOf returns the tenant the statements on m are scoped to. ok is false
+// when m has no tenant field or the scoping is bypassed.
+func tenantOf(ctx context.Context, m *model.Model) (val any, ok bool, err error) {
+	if m.Tenant == nil {
+		return nil, false, nil
+	}
+	if ctx == nil {
+		return nil, false, errs.ErrNoTenant
+	}
+	if bypass, _ := ctx.Value(noTenantKey{}).(bool); bypass {
+		return nil, false, nil
+	}
+	val, ok = TenantFrom(ctx)
+	if !ok {
+		return nil, false, errs.ErrNoTenant
+	}
+	if _, isParam := val.(Parameter); isParam {
+		return val, true, nil
+	}
+	// the driver gets the type of the column, e.g. int64 for an int tenant
+	rv := reflect.ValueOf(val)
+	if rv.Type() != m.Tenant.Type {
+		if !tenantConvertible(rv.Type(), m.Tenant.Type) {
+			return nil, false, errs.ErrUnsupportedType
+		}
+		val = rv.Convert(m.Tenant.Type).Interface()
+	}
+	return val, true, nil
+}
+
+// tenantConvertible only allows the conversions keeping the value, numeric
+// to numeric or between types of the same kind: Go converts an int to a
+// string as a rune.
+func tenantConvertible(from, to reflect.Type) bool {
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+	return from.Kind() == to.Kind() || (isNumeric(from.Kind()) && isNumeric(to.Kind()))
+}
+
+func isNumeric(k reflect.Kind) bool {
+	return (k >= reflect.Int && k <= reflect.Uint64) || k == reflect.Float32 || k == reflect.Float64
+}
+
+// assignsTenant tells whether assigns set the tenant field of m.
+func assignsTenant(m *model.Model, assigns []Assignable) bool {
+	for _, assign := range assigns {
+		var name string
+		switch a := assign.(type) {
+		case Assignment:
+			name = a.column.name
+		case Column:
+			name = a.name
+		}
+		if fd, ok := m.GoMap[name]; ok && fd == m.Tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantScope is the predicate on the tenant column of m, qualified by the
+// table name when the statement reads several tables.
+type tenantScope struct {
+	m         *model.Model
+	val       any
+	qualified bool
+}
+
+func (tenantScope) expr() {}
+
+func (b *builder) buildTenantScope(t tenantScope) {
+	if t.qualified {
+		b.buildTable(t.m)
+		b.buildByte('.')
+	}
+	b.quote(t.m.Tenant.ColName)
+	b.buildString(" = ?")
+	b.argCol = t.m.Tenant.ColName
+	b.addArgs(t.val)
+	b.argCol = ""
+}
+
+// scoped ANDs the tenant scope of m, if any, to the predicates.
+func scoped(ctx context.Context, m *model.Model, qualified bool, ps []Predicate) ([]Predicate, error) {
+	tenant, ok, err := tenantOf(ctx, m)
+	if err != nil || !ok {
+		return ps, err
+	}
+	res := make([]Predicate, len(ps), len(ps)+1)
+	copy(res, ps)
+	return append(res, Predicate{left: tenantScope{m: m, val: tenant, qualified: qualified}}), nil
+}
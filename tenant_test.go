@@ -0,0 +1,323 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kisara71/go-orm/errs"
+	"github.com/kisara71/go-orm/middleware"
+	"github.com/kisara71/go-orm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type TenantOrder struct {
+	Id       int64
+	TenantId int64 `orm:"tenant"`
+	Amount   int64
+}
+
+type TenantNote struct {
+	Id     int64
+	Tenant string `orm:"tenant"`
+}
+
+type TenantItem struct {
+	Id       int64
+	TenantId int64 `orm:"column=tenant,tenant"`
+}
+
+func TestRegistry_Tenant(t *testing.T) {
+	r := &model.Registry{}
+	m, err := r.Get(&TenantItem{})
+	require.NoError(t, err)
+	require.NotNil(t, m.Tenant)
+	assert.Equal(t, "tenant", m.Tenant.ColName)
+
+	type TwoTenants struct {
+		A int64 `orm:"tenant"`
+		B int64 `orm:"tenant"`
+	}
+	_, err = r.Get(&TwoTenants{})
+	assert.Equal(t, errs.ErrInvalidTags, err)
+
+	type UnknownFlag struct {
+		A int64 `orm:"primary"`
+	}
+	_, err = r.Get(&UnknownFlag{})
+	assert.Equal(t, errs.ErrInvalidTags, err)
+}
+
+func TestTenant_Build(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	type TestModel struct {
+		Id int64
+	}
+	tenantCtx := WithTenant(context.Background(), 7)
+
+	testCases := []struct {
+		name      string
+		ctx       context.Context
+		builder   Builder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "select",
+			ctx:     tenantCtx,
+			builder: NewSelector[TenantOrder](db),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `tenant_order` WHERE `tenant_id` = ?;",
+				Args: []any{int64(7)},
+			},
+		},
+		{
+			name:    "select where",
+			ctx:     tenantCtx,
+			builder: NewSelector[TenantOrder](db).Where(C("Id").Eq(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `tenant_order` WHERE (`id` = ?) AND (`tenant_id` = ?);",
+				Args: []any{1, int64(7)},
+			},
+		},
+		{
+			name: "join on",
+			ctx:  tenantCtx,
+			builder: NewSelector[TenantOrder](db).
+				From(TableOf(TenantOrder{}).Join(TableOf(TenantItem{})).On(C("Id").Eq(1))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `tenant_order` JOIN `tenant_item` ON (`id` = ?) AND (`tenant_item`.`tenant` = ?)" +
+					" WHERE `tenant_order`.`tenant_id` = ?;",
+				Args: []any{1, int64(7), int64(7)},
+			},
+		},
+		{
+			name: "join without on",
+			ctx:  tenantCtx,
+			builder: NewSelector[TenantOrder](db).
+				From(TableOf(TenantOrder{}).LeftJoin(TableOf(TenantItem{}))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `tenant_order` LEFT JOIN `tenant_item` ON `tenant_item`.`tenant` = ?" +
+					" WHERE `tenant_order`.`tenant_id` = ?;",
+				Args: []any{int64(7), int64(7)},
+			},
+		},
+		{
+			name: "join unscoped table",
+			ctx:  tenantCtx,
+			builder: NewSelector[TenantOrder](db).
+				From(TableOf(TenantOrder{}).Join(TableOf(TestModel{})).Using("Id")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `tenant_order` JOIN `test_model` USING (`id`) WHERE `tenant_order`.`tenant_id` = ?;",
+				Args: []any{int64(7)},
+			},
+		},
+		{
+			name:    "string tenant",
+			ctx:     WithTenant(context.Background(), "acme"),
+			builder: NewSelector[TenantNote](db),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `tenant_note` WHERE `tenant` = ?;",
+				Args: []any{"acme"},
+			},
+		},
+		{
+			// Go would convert 65 to "A"
+			name:    "int tenant on string column",
+			ctx:     WithTenant(context.Background(), 65),
+			builder: NewSelector[TenantNote](db),
+			wantErr: errs.ErrUnsupportedType,
+		},
+		{
+			name:    "int tenant on int64 column",
+			ctx:     WithTenant(context.Background(), uint8(7)),
+			builder: NewSelector[TenantOrder](db),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `tenant_order` WHERE `tenant_id` = ?;",
+				Args: []any{int64(7)},
+			},
+		},
+		{
+			name: "join using",
+			ctx:  tenantCtx,
+			builder: NewSelector[TenantOrder](db).
+				From(TableOf(TenantOrder{}).Join(TableOf(TenantItem{})).Using("Id")),
+			wantErr: errs.ErrUnsupported,
+		},
+		{
+			name:    "no tenant",
+			ctx:     context.Background(),
+			builder: NewSelector[TenantOrder](db),
+			wantErr: errs.ErrNoTenant,
+		},
+		{
+			name:    "bypassed",
+			ctx:     WithoutTenant(context.Background()),
+			builder: NewSelector[TenantOrder](db),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `tenant_order`;",
+				Args: []any{},
+			},
+		},
+		{
+			name:    "unscoped model",
+			ctx:     context.Background(),
+			builder: NewSelector[TestModel](db),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model`;",
+				Args: []any{},
+			},
+		},
+		{
+			name:    "update",
+			ctx:     tenantCtx,
+			builder: NewUpdater[TenantOrder](db).Set(Assign("Amount", 10)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `tenant_order` SET `amount` = ? WHERE `tenant_id` = ?;",
+				Args: []any{10, int64(7)},
+			},
+		},
+		{
+			name:    "update from struct",
+			ctx:     tenantCtx,
+			builder: NewUpdater[TenantOrder](db).FromStruct(&TenantOrder{TenantId: 8, Amount: 10}),
+			wantQuery: &Query{
+				SQL:  "UPDATE `tenant_order` SET `amount` = ? WHERE `tenant_id` = ?;",
+				Args: []any{int64(10), int64(7)},
+			},
+		},
+		{
+			name:    "update assigns tenant",
+			ctx:     tenantCtx,
+			builder: NewUpdater[TenantOrder](db).Set(Assign("Amount", 10), Assign("TenantId", 8)),
+			wantErr: errs.ErrTenantAssign,
+		},
+		{
+			name:    "update assigns tenant bypassed",
+			ctx:     WithoutTenant(context.Background()),
+			builder: NewUpdater[TenantOrder](db).Set(Assign("TenantId", 8)).Where(C("Id").Eq(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `tenant_order` SET `tenant_id` = ? WHERE `id` = ?;",
+				Args: []any{8, 1},
+			},
+		},
+		{
+			name: "upsert assigns tenant",
+			ctx:  tenantCtx,
+			builder: NewInsertor[TenantOrder](db).Values(&TenantOrder{Id: 1}).
+				OnConflict().Update(C("TenantId")),
+			wantErr: errs.ErrTenantAssign,
+		},
+		{
+			name: "delete",
+			ctx:  tenantCtx,
+			builder: func() Builder {
+				d := NewDeletor[TenantOrder](db)
+				d.Where(C("Id").Eq(1))
+				return d
+			}(),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `tenant_order` WHERE (`id` = ?) AND (`tenant_id` = ?)",
+				Args: []any{1, int64(7)},
+			},
+		},
+		{
+			name:    "insert",
+			ctx:     tenantCtx,
+			builder: NewInsertor[TenantOrder](db).Values(&TenantOrder{Id: 1}, &TenantOrder{Id: 2, TenantId: 7}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `tenant_order` (`id`, `tenant_id`, `amount`) VALUES (?, ?, ?), (?, ?, ?);",
+				Args: []any{int64(1), int64(7), int64(0), int64(2), int64(7), int64(0)},
+			},
+		},
+		{
+			name:    "insert columns",
+			ctx:     tenantCtx,
+			builder: NewInsertor[TenantOrder](db).Columns("Id").Values(&TenantOrder{Id: 1}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `tenant_order` (`id`, `tenant_id`) VALUES (?, ?);",
+				Args: []any{int64(1), int64(7)},
+			},
+		},
+		{
+			name:    "insert other tenant",
+			ctx:     tenantCtx,
+			builder: NewInsertor[TenantOrder](db).Values(&TenantOrder{Id: 1, TenantId: 8}),
+			wantErr: errs.ErrTenantMismatch,
+		},
+		{
+			name:    "insert no tenant",
+			ctx:     context.Background(),
+			builder: NewInsertor[TenantOrder](db).Values(&TenantOrder{Id: 1}),
+			wantErr: errs.ErrNoTenant,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.builder.BuildContext(tc.ctx)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestTenant_InsertBuild(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	orders := []*TenantOrder{{Id: 1}, {Id: 2, TenantId: 7}}
+	q, err := NewInsertor[TenantOrder](db).Values(orders...).BuildContext(WithTenant(context.Background(), int64(7)))
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(1), int64(7), int64(0), int64(2), int64(7), int64(0)}, q.Args)
+	// building leaves the entities untouched
+	assert.Equal(t, []*TenantOrder{{Id: 1}, {Id: 2, TenantId: 7}}, orders)
+}
+
+func TestTenant_Insert(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	mock.ExpectExec("INSERT INTO `tenant_order` (`id`, `tenant_id`, `amount`) VALUES (?, ?, ?);").
+		WithArgs(1, 7, 0).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	order := &TenantOrder{Id: 1}
+	res := NewInsertor[TenantOrder](db).Values(order).
+		Exec(&middleware.Context{Ctx: WithTenant(context.Background(), int64(7))})
+	require.NoError(t, res.Err())
+	// the entity is filled as well
+	assert.Equal(t, int64(7), order.TenantId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_Compiled(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+	query := "SELECT * FROM `tenant_order` WHERE (`id` = ?) AND (`tenant_id` = ?);"
+	mock.ExpectQuery(query).WithArgs(1, 7).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(query).WithArgs(1, 8).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	// converted to the type of the column as for the builders
+	mock.ExpectQuery(query).WithArgs(1, int64(9)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT * FROM `tenant_order` WHERE `id` = ?;").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	cs, err := NewSelector[TenantOrder](db).Where(C("Id").Eq(Param("id"))).Compile()
+	require.NoError(t, err)
+	for _, tenant := range []any{int64(7), int64(8), uint8(9)} {
+		_, err = cs.Get(&middleware.Context{Ctx: WithTenant(context.Background(), tenant)}, Bindings{"id": 1})
+		require.NoError(t, err)
+	}
+	_, err = cs.Get(&middleware.Context{Ctx: WithoutTenant(context.Background())}, Bindings{"id": 1})
+	require.NoError(t, err)
+	_, err = cs.Get(&middleware.Context{Ctx: context.Background()}, Bindings{"id": 1})
+	assert.Equal(t, errs.ErrNoTenant, err)
+	_, err = cs.Get(&middleware.Context{Ctx: WithTenant(context.Background(), "acme")}, Bindings{"id": 1})
+	assert.Equal(t, errs.ErrUnsupportedType, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,29 +4,51 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/kisara71/go-orm/errs"
 )
 
 type session interface {
 	getCore() core
 	queryContext(context.Context, string, ...any) (*sql.Rows, error)
 	execContext(context.Context, string, ...any) (sql.Result, error)
+	// shard returns the session of the idx-th sharding database.
+	shard(idx int) (session, error)
+}
+
+// Session is implemented by DB and Transaction, the builders run on it. It
+// is handed to hooks so that they can build further statements on the same
+// connection. Its methods are unexported, it cannot be implemented outside
+// this package: sessions without a database, e.g. for tests, are opened with
+// OpenEvaluator.
+type Session interface {
+	session
 }
 
 type Transaction struct {
 	db *DB
 	tx *sql.Tx
+	// stmts holds the cached statements of db bound to tx
+	stmts map[string]*sql.Stmt
+	// eval runs the builders instead of tx, see OpenEvaluator
+	eval EvaluatorTx
 }
 
 func (t *Transaction) Commit() error {
+	if t.eval != nil {
+		return t.eval.Commit()
+	}
 	return t.tx.Commit()
 }
 
 func (t *Transaction) RollBack() error {
+	if t.eval != nil {
+		return t.eval.Rollback()
+	}
 	return t.tx.Rollback()
 }
 
 func (t *Transaction) RollBackUnlessCommit() error {
-	err := t.tx.Rollback()
+	err := t.RollBack()
 	if !errors.Is(err, sql.ErrTxDone) {
 		return err
 	}
@@ -38,9 +60,55 @@ func (t *Transaction) getCore() core {
 }
 
 func (t *Transaction) queryContext(ctx context.Context, s string, a ...any) (*sql.Rows, error) {
-	return t.tx.QueryContext(ctx, s, a...)
+	if t.eval != nil {
+		return nil, errs.ErrUnsupported
+	}
+	if t.db.stmts == nil {
+		return t.tx.QueryContext(ctx, s, a...)
+	}
+	stmt, err := t.stmt(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, a...)
 }
 
 func (t *Transaction) execContext(ctx context.Context, s string, a ...any) (sql.Result, error) {
-	return t.tx.ExecContext(ctx, s, a...)
+	if t.eval != nil {
+		return nil, errs.ErrUnsupported
+	}
+	if t.db.stmts == nil {
+		return t.tx.ExecContext(ctx, s, a...)
+	}
+	stmt, err := t.stmt(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, a...)
+}
+
+// stmt re-binds the cached statement to the transaction. Bound statements
+// are closed by the driver when the transaction ends.
+func (t *Transaction) stmt(ctx context.Context, s string) (*sql.Stmt, error) {
+	if stmt, ok := t.stmts[s]; ok {
+		return stmt, nil
+	}
+	cached, release, err := t.db.stmts.prepare(ctx, t.db.db, s)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if t.stmts == nil {
+		t.stmts = make(map[string]*sql.Stmt, 8)
+	}
+	stmt := t.tx.StmtContext(ctx, cached)
+	t.stmts[s] = stmt
+	return stmt, nil
+}
+
+func (t *Transaction) shard(idx int) (session, error) {
+	if idx != 0 || len(t.db.shards) > 0 {
+		return nil, errs.ErrShardInTx
+	}
+	return t, nil
 }
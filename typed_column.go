@@ -0,0 +1,69 @@
+package go_orm
+
+// TypedColumn is a Column whose comparison and assignment values are bound
+// to the Go type of the model field, so a mismatch fails at compile time.
+// Values are usually declared by code generated with go-orm-cols.
+type TypedColumn[V any] struct {
+	col Column
+}
+
+func (TypedColumn[V]) assign()     {}
+func (TypedColumn[V]) selectable() {}
+func (TypedColumn[V]) expr()       {}
+
+// Column returns the untyped column, e.g. for OnConflict().Columns.
+func (c TypedColumn[V]) Column() Column {
+	return c.col
+}
+
+func Col[V any](name string) TypedColumn[V] {
+	return TypedColumn[V]{col: C(name)}
+}
+
+// Name returns the Go field name the column refers to.
+func (c TypedColumn[V]) Name() string {
+	return c.col.name
+}
+
+func (c TypedColumn[V]) As(alias string) TypedColumn[V] {
+	return TypedColumn[V]{col: c.col.As(alias)}
+}
+
+func (c TypedColumn[V]) Eq(val V) Predicate {
+	return c.col.Eq(val)
+}
+
+func (c TypedColumn[V]) LT(val V) Predicate {
+	return c.col.LT(val)
+}
+
+func (c TypedColumn[V]) GT(val V) Predicate {
+	return c.col.GT(val)
+}
+
+func (c TypedColumn[V]) Assign(val V) Assignment {
+	return Assignment{
+		column: c.col,
+		val:    val,
+	}
+}
+
+func (c TypedColumn[V]) ASC() OrderBy {
+	return OrderBy{
+		col:   c.col,
+		order: "ASC",
+	}
+}
+
+func (c TypedColumn[V]) DESC() OrderBy {
+	return OrderBy{
+		col:   c.col,
+		order: "DESC",
+	}
+}
+
+// columnar is implemented by every TypedColumn instantiation, letting the
+// builders treat them as plain columns.
+type columnar interface {
+	Column() Column
+}
@@ -0,0 +1,69 @@
+package go_orm
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTypedColumn_Build(t *testing.T) {
+	type TestModel struct {
+		Name string
+		Age  int
+	}
+	var cols = struct {
+		Name TypedColumn[string]
+		Age  TypedColumn[int]
+	}{
+		Name: Col[string]("Name"),
+		Age:  Col[int]("Age"),
+	}
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	testCases := []struct {
+		name      string
+		builder   Builder
+		wantQuery *Query
+	}{
+		{
+			name: "select where order",
+			builder: NewSelector[TestModel](db).
+				Select(cols.Name, cols.Age.As("years")).
+				Where(cols.Age.GT(18), cols.Name.Eq("wang")).
+				GroupBy(cols.Age).
+				OrderBy(cols.Age.DESC()),
+			wantQuery: &Query{
+				SQL: "SELECT `name`, `age` AS `years` FROM `test_model` WHERE (`age` > ?) AND (`name` = ?) " +
+					"GROUP BY `age` ORDER BY `age` DESC;",
+				Args: []any{18, "wang"},
+			},
+		},
+		{
+			name:    "update",
+			builder: NewUpdater[TestModel](db).Set(cols.Age.Assign(20)).Where(cols.Name.Eq("wang")),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age` = ? WHERE `name` = ?;",
+				Args: []any{20, "wang"},
+			},
+		},
+		{
+			name: "upsert",
+			builder: NewInsertor[TestModel](db).Values(&TestModel{Name: "wang", Age: 18}).
+				OnConflict().Update(cols.Age),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model` (`name`, `age`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `age` = VALUES(`age`);",
+				Args: []any{"wang", 18},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.builder.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package go_orm
 
 import (
 	"database/sql"
-	"errors"
 	errors2 "github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/model"
 	"reflect"
@@ -15,6 +14,9 @@ type UnsafeAccessor interface {
 	Access(entity any)
 }
 
+// AccessorFactory creates the UnsafeAccessor builders use for a model.
+type AccessorFactory func(m *model.Model) UnsafeAccessor
+
 type unsafeAccessor struct {
 	m      *model.Model
 	entity any
@@ -27,7 +29,7 @@ func NewUnsafeAccessor(model *model.Model) UnsafeAccessor {
 }
 func (u *unsafeAccessor) Set(rows *sql.Rows) error {
 	if u.entity == nil {
-		return errors.New("unsafe accessor has no vals")
+		return errors2.ErrNoEntity
 	}
 	cols, err := rows.Columns()
 	if err != nil {
@@ -40,6 +42,11 @@ func (u *unsafeAccessor) Set(rows *sql.Rows) error {
 		if !ok {
 			return errors2.ErrUnknownColumn
 		}
+		if fd.Indirect {
+			// behind an embedded pointer, which may need to be allocated
+			vals = append(vals, fd.Settable(reflect.ValueOf(u.entity).Elem()).Addr().Interface())
+			continue
+		}
 		vals = append(vals,
 			reflect.NewAt(fd.Type, unsafe.Pointer((uintptr)(address)+fd.Offset)).Interface())
 	}
@@ -55,8 +62,13 @@ func (u *unsafeAccessor) Access(entity any) {
 }
 
 func (u *unsafeAccessor) Fetch(field string) (any, error) {
+	if u.entity == nil {
+		return nil, errors2.ErrNoEntity
+	}
 	if fd, ok := u.m.GoMap[field]; !ok {
 		return nil, errors2.ErrUnknownField
+	} else if fd.Indirect {
+		return fd.Value(reflect.ValueOf(u.entity).Elem()).Interface(), nil
 	} else {
 		address := reflect.ValueOf(u.entity).UnsafePointer()
 		return reflect.NewAt(fd.Type,
@@ -1,23 +1,31 @@
 package go_orm
 
 import (
+	"context"
+	"database/sql"
 	"github.com/kisara71/go-orm/errs"
 	"github.com/kisara71/go-orm/middleware"
 	"reflect"
+	"slices"
 )
 
 var _ Builder = &Updater[any]{}
 
 type Updater[T any] struct {
-	builder *builder
-	val     *T
-	assigns []Assignable
-	where   []Predicate
-	core    core
-	sess    session
+	builder    *builder
+	val        *T
+	assigns    []Assignable
+	where      []Predicate
+	core       core
+	sess       Session
+	shardTable string
+	opts       queryOptions
+	skip       scopeSkip
+	// fullTable lets the statement run without WHERE, see AllowFullTable
+	fullTable bool
 }
 
-func NewUpdater[T any](sess session) *Updater[T] {
+func NewUpdater[T any](sess Session) *Updater[T] {
 	c := sess.getCore()
 	return &Updater[T]{
 		core:    c,
@@ -27,6 +35,17 @@ func NewUpdater[T any](sess session) *Updater[T] {
 	}
 }
 
+// Clone returns a copy of u which is modified and run independently of u.
+func (u *Updater[T]) Clone() *Updater[T] {
+	c := *u
+	c.assigns = slices.Clone(u.assigns)
+	c.where = slices.Clone(u.where)
+	c.opts = u.opts.clone()
+	c.skip = u.skip.clone()
+	c.builder, c.shardTable = nil, ""
+	return &c
+}
+
 func (u *Updater[T]) Set(assign ...Assignable) *Updater[T] {
 	u.assigns = append(u.assigns, assign...)
 	return u
@@ -37,11 +56,64 @@ func (u *Updater[T]) FromStruct(val *T) *Updater[T] {
 	return u
 }
 
+// AllowFullTable marks the statement as meant to update every row, so that
+// the guard middleware lets it run without WHERE.
+func (u *Updater[T]) AllowFullTable() *Updater[T] {
+	u.fullTable = true
+	return u
+}
+
+// Scopes applies scopes to the statement.
+func (u *Updater[T]) Scopes(scopes ...func(*Updater[T]) *Updater[T]) *Updater[T] {
+	for _, scope := range scopes {
+		u = scope(u)
+	}
+	return u
+}
+
+// Unscoped disables the default scopes named names, or all of them when
+// none is given.
+func (u *Updater[T]) Unscoped(names ...string) *Updater[T] {
+	u.skip.add(names)
+	return u
+}
+
+func (u *Updater[T]) With(opts ...QueryOption) *Updater[T] {
+	u.opts.apply(opts)
+	return u
+}
+
+// Where adds predicates to the WHERE clause, successive calls are combined
+// with AND.
 func (u *Updater[T]) Where(p ...Predicate) *Updater[T] {
 	u.where = append(u.where, p...)
 	return u
 }
-func (u *Updater[T]) Build(ctx *middleware.Context) error {
+
+// AndWhere is Where, the scopes filter every builder with AndWhere.
+func (u *Updater[T]) AndWhere(p ...Predicate) *Updater[T] {
+	return u.Where(p...)
+}
+
+// ToSQL returns the statement with its args interpolated, for debugging.
+// It is built as for context.Background(), see Render.
+func (u *Updater[T]) ToSQL() (string, error) {
+	return toSQL(u, u.core.dialect)
+}
+
+// Build builds the statement as for context.Background(), see BuildContext.
+func (u *Updater[T]) Build() (*Query, error) {
+	return u.BuildContext(context.Background())
+}
+
+// BuildContext builds the statement for ctx without running it, u is left
+// untouched.
+func (u *Updater[T]) BuildContext(ctx context.Context) (*Query, error) {
+	c := *u
+	return buildQuery(ctx, &c)
+}
+
+func (u *Updater[T]) build(ctx *middleware.Context) error {
 
 	m, err := u.core.registry.Get(new(T))
 	if err != nil {
@@ -49,17 +121,26 @@ func (u *Updater[T]) Build(ctx *middleware.Context) error {
 	}
 
 	u.builder = NewBuilder(m, u.core.dialect)
+	u.builder.table = u.shardTable
+	u.builder.buildComment(u.opts.comment)
 
 	u.builder.buildString("UPDATE ")
-	u.builder.quote(u.builder.m.TableName)
+	u.builder.buildTable(u.builder.m)
 	u.builder.buildString(" SET ")
+	u.builder.stmt = Statement{Kind: middleware.KindUpdate, Model: m}
 
+	// a scoped update never moves the rows to another tenant
+	_, scopedTenant, err := tenantOf(ctx.Ctx, m)
+	if err != nil {
+		return err
+	}
 	if u.val != nil {
 		val := reflect.ValueOf(u.val).Elem()
 		idx := 0
 		for _, fd := range u.builder.m.Fields {
-			fieldVal := val.FieldByName(fd.GoName)
-			if fieldVal.IsZero() {
+			// zero behind a nil embedded pointer, so left untouched
+			fieldVal := fd.Value(val)
+			if fieldVal.IsZero() || (scopedTenant && fd == m.Tenant) {
 				continue
 			}
 			if idx > 0 {
@@ -67,13 +148,19 @@ func (u *Updater[T]) Build(ctx *middleware.Context) error {
 			}
 			u.builder.quote(fd.ColName)
 			u.builder.buildString(" = ?")
+			unbind := u.builder.bindColumn(fd.GoName)
 			u.builder.addArgs(fieldVal.Interface())
+			unbind()
+			u.builder.stmt.sets = append(u.builder.stmt.sets, set{field: fd, val: fieldVal.Interface()})
 			idx++
 		}
 	} else {
 		if len(u.assigns) == 0 {
 			return errs.ErrUpdateNoColumns
 		}
+		if scopedTenant && assignsTenant(m, u.assigns) {
+			return errs.ErrTenantAssign
+		}
 		for idx, assign := range u.assigns {
 			if idx > 0 {
 				u.builder.buildString(", ")
@@ -84,41 +171,81 @@ func (u *Updater[T]) Build(ctx *middleware.Context) error {
 					return err
 				}
 				u.builder.buildString(" = ?")
+				unbind := u.builder.bindColumn(a.column.name)
 				u.builder.addArgs(a.val)
+				unbind()
+				u.builder.stmt.sets = append(u.builder.stmt.sets, set{field: m.GoMap[a.column.name], val: a.val})
 			default:
 				return errs.ErrUnsupportedType
 			}
 		}
 	}
-	if len(u.where) > 0 {
+	where := defaultScoped(ctx.Ctx, u.core, new(T), u.skip, u.where)
+	where, err = scoped(ctx.Ctx, u.builder.m, false, where)
+	if err != nil {
+		return err
+	}
+	u.builder.stmt.where = where
+	if len(where) > 0 {
 		u.builder.buildString(" WHERE ")
-		p := u.where[0]
-		for i := 1; i < len(u.where); i++ {
-			p = p.And(u.where[i])
+		p := where[0]
+		for i := 1; i < len(where); i++ {
+			p = p.And(where[i])
+		}
+		if err = u.builder.buildExpression(p, ClauseWhere); err != nil {
+			return err
 		}
-		err = u.builder.buildExpression(p, ClauseWhere)
 	}
 	u.builder.buildByte(';')
+	ctx.Model = u.builder.m
+	ctx.Kind = middleware.KindUpdate
+	ctx.Tables = u.builder.tables
+	ctx.HasWhere = len(u.where) > 0
+	ctx.FullTable = u.fullTable
 	ctx.SetStatement(u.builder.getSQL())
 	ctx.SetArgs(u.builder.getArgs())
+	ctx.ArgColumns = u.builder.getArgColumns()
 	return nil
 }
 
+func (u *Updater[T]) exec(ctx *middleware.Context, sess session) (sql.Result, error) {
+	if ev, ok := evaluatorOf(sess); ok {
+		return ev.Exec(ctx.Ctx, &u.builder.stmt)
+	}
+	rule, ok := shardingRuleOf[T]()
+	if !ok {
+		return sess.execContext(ctx.Ctx, ctx.Statement, ctx.Args...)
+	}
+	dsts, err := shardDsts(rule, u.where)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		u.shardTable = ""
+	}()
+	return execShards(ctx, sess, dsts, func(ctx *middleware.Context, _ int, dst ShardDst) error {
+		u.shardTable = dst.Table
+		return u.build(ctx)
+	})
+}
+
 var _ middleware.Handler = (&Updater[any]{}).handleExec
 
+// handleExec expects ctx to hold the statement built by Exec.
 func (u *Updater[T]) handleExec(ctx *middleware.Context) *middleware.Result {
-	err := u.Build(ctx)
+	res, err := auditExec[T](ctx, u.core, u.sess, middleware.KindUpdate, u.where, u.skip, u.builder.stmt.sets, u.exec)
 	if err != nil {
 		return &middleware.Result{
 			Res: nil,
 			Err: err,
 		}
 	}
-	res, err := u.sess.execContext(ctx.Ctx, ctx.Statement, ctx.Args...)
-	if err != nil {
-		return &middleware.Result{
-			Res: nil,
-			Err: err,
+	if u.val != nil {
+		if err = afterUpdate(ctx.Ctx, u.sess, u.val); err != nil {
+			return &middleware.Result{
+				Res: nil,
+				Err: err,
+			}
 		}
 	}
 	return &middleware.Result{
@@ -129,13 +256,31 @@ func (u *Updater[T]) handleExec(ctx *middleware.Context) *middleware.Result {
 		Err: nil,
 	}
 }
+func (u *Updater[T]) before(ctx *middleware.Context) error {
+	if u.val == nil {
+		return nil
+	}
+	return beforeUpdate(ctx.Ctx, u.sess, u.val)
+}
+
 func (u *Updater[T]) Exec(ctx *middleware.Context) *ExecResult {
+	// run on a copy, u may be shared by several goroutines
+	c := *u
+	u = &c
 	ctx.Type = middleware.OpExec
-	root := u.handleExec
-	for i := len(u.core.mdls) - 1; i >= 0; i-- {
-		root = u.core.mdls[i](root)
+	if err := u.before(ctx); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
+	}
+	if err := u.build(ctx); err != nil {
+		return &ExecResult{
+			res: nil,
+			err: err,
+		}
 	}
-	res := root(ctx)
+	res := u.core.run(ctx, u.opts, u.handleExec)
 	if res.Err != nil {
 		return &ExecResult{
 			res: nil,
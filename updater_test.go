@@ -1,10 +1,8 @@
 package go_orm
 
 import (
-	"context"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/kisara71/go-orm/errs"
-	"github.com/kisara71/go-orm/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -106,6 +104,16 @@ func TestUpdater_Build(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "update with where unknown field",
+			builder: func() *Updater[TestModel] {
+				u := NewUpdater[TestModel](db)
+				u.Set(Assignment{column: C("Age"), val: 20})
+				u.Where(C("Missing").Eq(1))
+				return u
+			}(),
+			wantErr: errs.ErrUnknownField,
+		},
 		{
 			name: "update with where raw",
 			builder: func() *Updater[TestModel] {
@@ -152,16 +160,66 @@ func TestUpdater_Build(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := &middleware.Context{Ctx: context.Background()}
-			err := tc.builder.Build(ctx)
+			q, err := tc.builder.Build()
 			assert.Equal(t, tc.wantErr, err)
 			if err != nil {
 				return
 			}
-			assert.Equal(t, tc.wantQuery, &Query{
-				SQL:  ctx.Statement,
-				Args: ctx.Args,
-			})
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+type updaterTestBase struct {
+	CreatedBy string
+}
+
+type updaterTestModel struct {
+	*updaterTestBase
+	Name  string
+	level int
+}
+
+func TestUpdater_FromStructEmbedded(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db := OpenDB(mockDB, WithDialect(MySQLDialect))
+
+	testCases := []struct {
+		name      string
+		entity    *updaterTestModel
+		wantQuery *Query
+	}{
+		{
+			name:   "nil embedded pointer",
+			entity: &updaterTestModel{Name: "tom"},
+			wantQuery: &Query{
+				SQL:  "UPDATE `updater_test_model` SET `name` = ?;",
+				Args: []any{"tom"},
+			},
+		},
+		{
+			name:   "embedded pointer",
+			entity: &updaterTestModel{updaterTestBase: &updaterTestBase{CreatedBy: "ann"}, Name: "tom"},
+			wantQuery: &Query{
+				SQL:  "UPDATE `updater_test_model` SET `created_by` = ?, `name` = ?;",
+				Args: []any{"ann", "tom"},
+			},
+		},
+		{
+			name:   "unexported",
+			entity: &updaterTestModel{Name: "tom", level: 2},
+			wantQuery: &Query{
+				SQL:  "UPDATE `updater_test_model` SET `name` = ?, `level` = ?;",
+				Args: []any{"tom", 2},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := NewUpdater[updaterTestModel](db).FromStruct(tc.entity).Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
 		})
 	}
 }
@@ -3,8 +3,23 @@ package utils
 import (
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+var commonInitialisms = map[string]string{
+	"api":  "API",
+	"http": "HTTP",
+	"id":   "ID",
+	"ip":   "IP",
+	"json": "JSON",
+	"sql":  "SQL",
+	"uid":  "UID",
+	"uri":  "URI",
+	"url":  "URL",
+	"uuid": "UUID",
+}
+
 func CamelToSnake(s string) string {
 	re1 := regexp.MustCompile(`([A-Z]+)([A-Z][a-z])`)
 	s = re1.ReplaceAllString(s, "${1}_${2}")
@@ -13,3 +28,21 @@ func CamelToSnake(s string) string {
 
 	return strings.ToLower(s)
 }
+
+func SnakeToCamel(s string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	}) {
+		lower := strings.ToLower(word)
+		if initialism, ok := commonInitialisms[lower]; ok {
+			sb.WriteString(initialism)
+			continue
+		}
+		// the first rune may span several bytes
+		r, size := utf8.DecodeRuneInString(lower)
+		sb.WriteRune(unicode.ToUpper(r))
+		sb.WriteString(lower[size:])
+	}
+	return sb.String()
+}
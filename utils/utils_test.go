@@ -49,3 +49,48 @@ func TestCamelToSnake(t *testing.T) {
 		})
 	}
 }
+
+func TestSnakeToCamel(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{
+			name:   "basic snake case",
+			input:  "snake_case",
+			expect: "SnakeCase",
+		},
+		{
+			name:   "initialism",
+			input:  "user_id",
+			expect: "UserID",
+		},
+		{
+			name:   "upper case words",
+			input:  "ORDER_ITEM",
+			expect: "OrderItem",
+		},
+		{
+			name:   "repeated separators",
+			input:  "created__at_",
+			expect: "CreatedAt",
+		},
+		{
+			name:   "single word",
+			input:  "username",
+			expect: "Username",
+		},
+		{
+			name:   "non ascii",
+			input:  "émission_año",
+			expect: "ÉmissionAño",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := SnakeToCamel(tc.input)
+			assert.Equal(t, tc.expect, res)
+		})
+	}
+}